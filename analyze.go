@@ -0,0 +1,510 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cpu/cpu"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+const (
+	//idlePercentage metric with percent of time spent in the idle task
+	idlePercentage = "idle_percentage"
+
+	//activePercentage metric with percent of time spent in non idle state
+	activePercentage = "active_percentage"
+
+	//countersRepresentation representation of metrics with counters of CPU states
+	countersRepresentation = "jiffies"
+
+	//percentagesRepresentation representation of metrics with percentages of CPU states
+	percentagesRepresentation = "percentage"
+
+	//aggregateCPU identifier of aggregate line of /proc/stat
+	aggregateCPU = "all"
+
+	//percentageTolerance tolerance of sanity checks of percentages
+	percentageTolerance = 0.01
+
+	//cgroupV2UsageKey key of cpu.stat of cgroup v2 with CPU time used by cgroup in microseconds
+	cgroupV2UsageKey = "usage_usec"
+
+	//cgroupV1UsageKey key under which content of cpuacct.usage of cgroup v1 (CPU time in nanoseconds) is reported
+	cgroupV1UsageKey = "usage_nsec"
+
+	//cgroupPeriodsKey key of cpu.stat with number of enforcement periods of CPU quota elapsed
+	cgroupPeriodsKey = "nr_periods"
+
+	//cgroupThrottledKey key of cpu.stat with number of periods in which cgroup was throttled
+	cgroupThrottledKey = "nr_throttled"
+)
+
+//analyzedCapture metrics read from capture of machine
+type analyzedCapture struct {
+	name     string
+	snapshot cpu.Snapshot
+	hostname string
+	names    []string                      // names of metrics in the order of registry
+	values   map[string]map[string]float64 // values by CPU identifier and name of metric
+}
+
+//sanityCheck result of check of captured data
+type sanityCheck struct {
+	description string
+	passed      bool
+}
+
+//analyze reads capture of machine, e.g. sosreport or tar archive of its /proc and /sys, and prints report
+//of its CPU topology, counters and cgroup usage; when capture taken later is given as well, percentages
+//and cgroup usage are calculated over the time between them
+func analyze(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	before := flags.String("before", "", "captured root directory or tar archive of it")
+	after := flags.String("after", "", "capture of the same machine taken later, needed for percentages")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *before == "" {
+		return fmt.Errorf("Missing -before")
+	}
+	paths := []string{*before}
+	if *after != "" {
+		paths = append(paths, *after)
+	}
+
+	captures := []*analyzedCapture{}
+	for _, path := range paths {
+		snapshot, err := cpu.ReadCapture(path)
+		if err != nil {
+			return fmt.Errorf("Cannot read capture %s: %v", path, err)
+		}
+		//each capture is read by separate plugin, so captures of machines with different CPUs can be compared
+		capture, metrics, err := collectCaptures([]cpu.Snapshot{snapshot})
+		if err != nil {
+			return fmt.Errorf("Cannot analyze capture %s: %v", path, err)
+		}
+		capture.name = path
+		capture.setValues(metrics)
+		captures = append(captures, capture)
+	}
+
+	for _, capture := range captures {
+		fmt.Fprintf(out, "Capture %s taken at %s\n", capture.name, capture.snapshot.Timestamp.Format(time.RFC3339))
+	}
+	fmt.Fprintf(out, "Hostname: %s\n", captures[0].hostname)
+	fmt.Fprintf(out, "CPUs: %d\n\n", len(captures[0].values)-1)
+	if err := writeTopology(out, captures[len(captures)-1]); err != nil {
+		return err
+	}
+	for _, capture := range captures {
+		fmt.Fprintf(out, "Counters of %s:\n", capture.name)
+		if err := writeAnalyzedValues(out, capture, countersRepresentation); err != nil {
+			return err
+		}
+	}
+	for _, capture := range captures {
+		if err := writeCgroupUsage(out, capture); err != nil {
+			return err
+		}
+	}
+
+	checks := []sanityCheck{}
+	if len(captures) == 2 {
+		first, second := captures[0], captures[1]
+		checks = append(checks, checkCaptures(first, second)...)
+		if checks[0].passed && checks[1].passed {
+			interval, metrics, err := collectCaptures([]cpu.Snapshot{first.snapshot, second.snapshot})
+			if err != nil {
+				return err
+			}
+			interval.setValues(metrics)
+			fmt.Fprintf(out, "Percentages over %s:\n", second.snapshot.Timestamp.Sub(first.snapshot.Timestamp))
+			if err := writeAnalyzedValues(out, interval, percentagesRepresentation); err != nil {
+				return err
+			}
+			checks = append(checks, checkPercentages(interval)...)
+			writeCgroupUsageOverInterval(out, first, second)
+		}
+	}
+	checks = append(checks, checkCounters(captures[len(captures)-1]))
+
+	fmt.Fprintln(out, "Sanity checks:")
+	failed := 0
+	for _, check := range checks {
+		result := "OK"
+		if !check.passed {
+			result = "FAILED"
+			failed++
+		}
+		fmt.Fprintf(out, "  [%s] %s\n", result, check.description)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d sanity checks failed", failed)
+	}
+	return nil
+}
+
+//collectCaptures feeds snapshots through new plugin in the given order and returns metrics collected from the last one
+func collectCaptures(snapshots []cpu.Snapshot) (*analyzedCapture, []plugin.Metric, error) {
+	replayed := &cpu.Replay{}
+	p := cpu.NewWithFileSystem(replayed)
+	defer p.Close()
+	capture := &analyzedCapture{snapshot: snapshots[len(snapshots)-1]}
+	var metrics []plugin.Metric
+	for _, snapshot := range snapshots {
+		replayed.Advance(snapshot)
+		mts, err := requestAllMetrics(p, plugin.Config{"proc_path": "/proc", "hostname_from_root": true})
+		if err != nil {
+			return nil, nil, err
+		}
+		capture.names = capture.names[:0]
+		for _, mt := range mts {
+			capture.names = append(capture.names, mt.Namespace[len(mt.Namespace)-1].Value)
+		}
+		if metrics, err = p.CollectMetrics(mts); err != nil {
+			return nil, nil, err
+		}
+	}
+	return capture, metrics, nil
+}
+
+//setValues stores values of collected metrics
+func (c *analyzedCapture) setValues(metrics []plugin.Metric) {
+	c.values = map[string]map[string]float64{}
+	for _, metric := range metrics {
+		ns := metric.Namespace.Strings()
+		value, ok := toFloat(metric.Data)
+		if !ok {
+			continue
+		}
+		cpuID := ns[len(ns)-2]
+		if c.values[cpuID] == nil {
+			c.values[cpuID] = map[string]float64{}
+		}
+		c.values[cpuID][ns[len(ns)-1]] = value
+		c.hostname = metric.Tags["hostname"]
+	}
+}
+
+//cpuIDs returns identifiers of CPUs in capture, aggregate first and the others in numerical order
+func (c *analyzedCapture) cpuIDs() []string {
+	ids := make([]string, 0, len(c.values))
+	for id := range c.values {
+		ids = append(ids, id)
+	}
+	sort.Sort(byCPUID(ids))
+	return ids
+}
+
+//byCPUID sorts identifiers of CPUs, aggregate first and the others in numerical order
+type byCPUID []string
+
+func (b byCPUID) Len() int      { return len(b) }
+func (b byCPUID) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byCPUID) Less(i, j int) bool {
+	if b[i] == aggregateCPU || b[j] == aggregateCPU {
+		return b[i] == aggregateCPU && b[j] != aggregateCPU
+	}
+	first, _ := strconv.Atoi(b[i])
+	second, _ := strconv.Atoi(b[j])
+	return first < second
+}
+
+//writeAnalyzedValues writes table of values of metrics in given representation, a row per CPU
+func writeAnalyzedValues(out io.Writer, capture *analyzedCapture, representation string) error {
+	names := []string{}
+	for _, name := range capture.names {
+		if descriptor, ok := cpu.DescribeMetric(name); ok && descriptor.Representation == representation {
+			names = append(names, name)
+		}
+	}
+	precision := -1
+	if representation == percentagesRepresentation {
+		precision = 2
+	}
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', tabwriter.AlignRight)
+	header := []string{"CPU"}
+	for _, name := range names {
+		descriptor, _ := cpu.DescribeMetric(name)
+		header = append(header, descriptor.State)
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t")+"\t")
+	for _, id := range capture.cpuIDs() {
+		row := []string{id}
+		for _, name := range names {
+			value, ok := capture.values[id][name]
+			if !ok {
+				row = append(row, "-")
+				continue
+			}
+			row = append(row, strconv.FormatFloat(value, 'f', precision, 64))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t")+"\t")
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(out)
+	return err
+}
+
+//checkCaptures checks whether captures come from the same machine in the order they were taken,
+//the first two checks must pass for percentages to be calculated
+func checkCaptures(first *analyzedCapture, second *analyzedCapture) []sanityCheck {
+	sameFormat := len(first.values) == len(second.values) && len(first.names) == len(second.names)
+	for id := range first.values {
+		if _, ok := second.values[id]; !ok {
+			sameFormat = false
+		}
+	}
+	growing := true
+	for id, values := range first.values {
+		for name, value := range values {
+			if later, ok := second.values[id][name]; ok && later < value {
+				growing = false
+			}
+		}
+	}
+	return []sanityCheck{
+		{"captures report the same CPUs and /proc/stat columns", sameFormat},
+		{"counters do not decrease between captures (no reboot in between)", growing},
+		{"captures come from the same host", first.hostname == second.hostname},
+		{"the second capture was taken after the first one", second.snapshot.Timestamp.After(first.snapshot.Timestamp)},
+	}
+}
+
+//checkPercentages checks whether percentages calculated between captures are consistent
+func checkPercentages(interval *analyzedCapture) []sanityCheck {
+	inRange, complementary := true, true
+	for _, values := range interval.values {
+		for name, value := range values {
+			if descriptor, ok := cpu.DescribeMetric(name); !ok || descriptor.Representation != percentagesRepresentation {
+				continue
+			}
+			if value < -percentageTolerance || value > 100+percentageTolerance || math.IsNaN(value) {
+				inRange = false
+			}
+		}
+		idle, idleOk := values[idlePercentage]
+		active, activeOk := values[activePercentage]
+		if !idleOk || !activeOk || math.Abs(idle+active-100) > percentageTolerance {
+			complementary = false
+		}
+	}
+	return []sanityCheck{
+		{"percentages are between 0 and 100", inRange},
+		{"idle and active percentages add up to 100", complementary},
+	}
+}
+
+//checkCounters checks whether counters of all CPUs add up to counters of aggregate line
+func checkCounters(capture *analyzedCapture) sanityCheck {
+	consistent := true
+	for name, total := range capture.values[aggregateCPU] {
+		if descriptor, ok := cpu.DescribeMetric(name); !ok || descriptor.Kind != cpu.CumulativeKind {
+			continue
+		}
+		sum := 0.0
+		for id, values := range capture.values {
+			if id != aggregateCPU {
+				sum += values[name]
+			}
+		}
+		// kernel sums counters of online CPUs only, so the aggregate may be greater
+		if sum > total {
+			consistent = false
+		}
+	}
+	return sanityCheck{"counters of CPUs do not exceed counters of aggregate line", consistent}
+}
+
+//cpuTopology position of CPU in topology of machine
+type cpuTopology struct {
+	pkg  string // physical_package_id
+	core string // core_id, unique within package
+}
+
+//readTopology returns topology of CPUs captured in snapshot keyed by CPU identifier,
+//CPUs whose topology was not captured are left out
+func readTopology(snapshot cpu.Snapshot, ids []string) map[string]cpuTopology {
+	topology := map[string]cpuTopology{}
+	for _, id := range ids {
+		number, err := strconv.Atoi(id)
+		if err != nil {
+			continue
+		}
+		pkg, pkgOk := snapshot.Files[cpu.TopologyPath(number, cpu.TopologyFiles[0])]
+		core, coreOk := snapshot.Files[cpu.TopologyPath(number, cpu.TopologyFiles[1])]
+		if pkgOk && coreOk {
+			topology[id] = cpuTopology{pkg: strings.TrimSpace(pkg), core: strings.TrimSpace(core)}
+		}
+	}
+	return topology
+}
+
+//writeTopology writes topology of CPUs of capture, i.e. package and core of each CPU and their numbers
+func writeTopology(out io.Writer, capture *analyzedCapture) error {
+	ids := capture.cpuIDs()
+	topology := readTopology(capture.snapshot, ids)
+	if len(topology) == 0 {
+		_, err := fmt.Fprintf(out, "Topology: not captured\n\n")
+		return err
+	}
+	packages, cores := map[string]bool{}, map[cpuTopology]bool{}
+	for _, position := range topology {
+		packages[position.pkg] = true
+		cores[position] = true
+	}
+	fmt.Fprintf(out, "Topology: %d packages, %d cores, %d CPUs\n", len(packages), len(cores), len(topology))
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "CPU\tpackage\tcore\t")
+	for _, id := range ids {
+		position, ok := topology[id]
+		if !ok {
+			if id != aggregateCPU {
+				fmt.Fprintf(w, "%s\t-\t-\t\n", id)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", id, position.pkg, position.core)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(out)
+	return err
+}
+
+//cgroupUsage CPU usage of cgroup read from one of cgroup files
+type cgroupUsage struct {
+	path   string
+	keys   []string // keys in the order of file
+	values map[string]uint64
+}
+
+//readCgroupUsage returns CPU usage of cgroup captured in snapshot, one per captured cgroup file;
+//cpuacct.usage is reported under cgroupV1UsageKey and lines which are not counters are skipped
+func readCgroupUsage(snapshot cpu.Snapshot) []cgroupUsage {
+	usages := []cgroupUsage{}
+	for _, path := range cpu.CgroupFiles {
+		content, ok := snapshot.Files[path]
+		if !ok {
+			continue
+		}
+		usage := cgroupUsage{path: path, values: map[string]uint64{}}
+		for _, line := range strings.Split(content, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 1 && strings.HasSuffix(path, "cpuacct.usage") {
+				fields = []string{cgroupV1UsageKey, fields[0]}
+			}
+			if len(fields) != 2 {
+				continue
+			}
+			value, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			usage.keys = append(usage.keys, fields[0])
+			usage.values[fields[0]] = value
+		}
+		usages = append(usages, usage)
+	}
+	return usages
+}
+
+//writeCgroupUsage writes counters of CPU usage of cgroup captured in capture
+func writeCgroupUsage(out io.Writer, capture *analyzedCapture) error {
+	usages := readCgroupUsage(capture.snapshot)
+	if len(usages) == 0 {
+		_, err := fmt.Fprintf(out, "Cgroup usage of %s: not captured\n\n", capture.name)
+		return err
+	}
+	fmt.Fprintf(out, "Cgroup usage of %s:\n", capture.name)
+	for _, usage := range usages {
+		fmt.Fprintf(out, "  %s\n", usage.path)
+		w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+		for _, key := range usage.keys {
+			fmt.Fprintf(w, "    %s\t%d\n", key, usage.values[key])
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(out)
+	return err
+}
+
+//writeCgroupUsageOverInterval writes average number of CPUs used by cgroup and percentage of throttled periods
+//of its CPU quota between captures, calculated from cgroup files captured in both of them
+func writeCgroupUsageOverInterval(out io.Writer, first *analyzedCapture, second *analyzedCapture) {
+	seconds := second.snapshot.Timestamp.Sub(first.snapshot.Timestamp).Seconds()
+	earlier := map[string]cgroupUsage{}
+	for _, usage := range readCgroupUsage(first.snapshot) {
+		earlier[usage.path] = usage
+	}
+	lines := []string{}
+	for _, usage := range readCgroupUsage(second.snapshot) {
+		prev, ok := earlier[usage.path]
+		if !ok {
+			continue
+		}
+		if used, ok := counterDelta(prev, usage, cgroupV2UsageKey); ok && seconds > 0 {
+			lines = append(lines, fmt.Sprintf("  %s: %.2f CPUs used", usage.path, float64(used)/1e6/seconds))
+		}
+		if used, ok := counterDelta(prev, usage, cgroupV1UsageKey); ok && seconds > 0 {
+			lines = append(lines, fmt.Sprintf("  %s: %.2f CPUs used", usage.path, float64(used)/1e9/seconds))
+		}
+		periods, periodsOk := counterDelta(prev, usage, cgroupPeriodsKey)
+		throttled, throttledOk := counterDelta(prev, usage, cgroupThrottledKey)
+		if periodsOk && throttledOk && periods > 0 {
+			lines = append(lines, fmt.Sprintf("  %s: %.2f%% of periods throttled", usage.path, 100*float64(throttled)/float64(periods)))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "Cgroup usage over %s:\n%s\n\n", second.snapshot.Timestamp.Sub(first.snapshot.Timestamp), strings.Join(lines, "\n"))
+}
+
+//counterDelta returns growth of counter with given key between usages, it is not ok when counter is missing
+//in any of them or goes back
+func counterDelta(prev cgroupUsage, curr cgroupUsage, key string) (uint64, bool) {
+	before, ok := prev.values[key]
+	if !ok {
+		return 0, false
+	}
+	after, ok := curr.values[key]
+	if !ok || after < before {
+		return 0, false
+	}
+	return after - before, true
+}
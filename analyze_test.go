@@ -0,0 +1,145 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	beforeProcStatSample = `cpu  200 0 200 1600 0 0 0 0 0 0
+cpu0 100 0 100 800 0 0 0 0 0 0
+cpu1 100 0 100 800 0 0 0 0 0 0`
+
+	afterProcStatSample = `cpu  300 0 300 1800 0 0 0 0 0 0
+cpu0 150 0 150 900 0 0 0 0 0 0
+cpu1 150 0 150 900 0 0 0 0 0 0`
+
+	beforeCgroupStat = "usage_usec 1000000\nuser_usec 600000\nsystem_usec 400000\nnr_periods 100\nnr_throttled 10\nthrottled_usec 5000\n"
+
+	afterCgroupStat = "usage_usec 16000000\nuser_usec 9600000\nsystem_usec 6400000\nnr_periods 200\nnr_throttled 35\nthrottled_usec 9000\n"
+)
+
+//newCapture creates captured root directory with given /proc/stat and cgroup v2 cpu.stat taken at given time
+func newCapture(parent string, name string, procStat string, cgroupStat string, takenAt time.Time) string {
+	root := filepath.Join(parent, name)
+	files := map[string]string{
+		"proc/stat":    procStat,
+		"etc/hostname": "node-17\n",
+		"sys/devices/system/cpu/cpu0/topology/physical_package_id": "0\n",
+		"sys/devices/system/cpu/cpu0/topology/core_id":             "0\n",
+		"sys/devices/system/cpu/cpu1/topology/physical_package_id": "0\n",
+		"sys/devices/system/cpu/cpu1/topology/core_id":             "0\n",
+		"sys/fs/cgroup/cpu.stat":                                   cgroupStat,
+	}
+	for path, content := range files {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			panic(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			panic(err)
+		}
+	}
+	if err := os.Chtimes(filepath.Join(root, "proc", "stat"), takenAt, takenAt); err != nil {
+		panic(err)
+	}
+	return root
+}
+
+func TestAnalyze(t *testing.T) {
+	Convey("Given captures of machine", t, func() {
+		parent, err := ioutil.TempDir("", "snap-plugin-collector-cpu")
+		So(err, ShouldBeNil)
+		Reset(func() { os.RemoveAll(parent) })
+		takenAt := time.Date(2016, 11, 17, 12, 0, 0, 0, time.UTC)
+		before := newCapture(parent, "before", beforeProcStatSample, beforeCgroupStat, takenAt)
+		after := newCapture(parent, "after", afterProcStatSample, afterCgroupStat, takenAt.Add(10*time.Second))
+		out := &bytes.Buffer{}
+
+		Convey("single capture should be reported with its counters", func() {
+			So(analyze([]string{"-before", before}, out), ShouldBeNil)
+			So(out.String(), ShouldContainSubstring, "Hostname: node-17\n")
+			So(out.String(), ShouldContainSubstring, "CPUs: 2\n")
+			So(out.String(), ShouldContainSubstring, "Counters of "+before)
+			So(out.String(), ShouldNotContainSubstring, "Percentages")
+			So(out.String(), ShouldContainSubstring, "[OK] counters of CPUs do not exceed counters of aggregate line")
+		})
+
+		Convey("single capture should be reported with topology and cgroup usage", func() {
+			So(analyze([]string{"-before", before}, out), ShouldBeNil)
+			So(out.String(), ShouldContainSubstring, "Topology: 1 packages, 1 cores, 2 CPUs\n")
+			So(out.String(), ShouldContainSubstring, "  1        0     0\n")
+			So(out.String(), ShouldContainSubstring, "Cgroup usage of "+before+":\n  sys/fs/cgroup/cpu.stat\n    usage_usec      1000000\n")
+			So(out.String(), ShouldNotContainSubstring, "Cgroup usage over")
+		})
+
+		Convey("pair of captures should be reported with percentages", func() {
+			So(analyze([]string{"-before", before, "-after", after}, out), ShouldBeNil)
+			So(out.String(), ShouldContainSubstring, "Percentages over 10s:")
+			So(out.String(), ShouldContainSubstring, "  all  25.00  0.00   25.00  50.00")
+			So(out.String(), ShouldContainSubstring, "Cgroup usage over 10s:\n  sys/fs/cgroup/cpu.stat: 1.50 CPUs used\n  sys/fs/cgroup/cpu.stat: 25.00% of periods throttled\n")
+			So(out.String(), ShouldNotContainSubstring, "FAILED")
+		})
+
+		Convey("captures in wrong order should fail sanity checks", func() {
+			So(analyze([]string{"-before", after, "-after", before}, out), ShouldNotBeNil)
+			So(out.String(), ShouldContainSubstring, "[FAILED] counters do not decrease between captures")
+			So(out.String(), ShouldNotContainSubstring, "Percentages")
+		})
+
+		Convey("gzipped tar archive of capture should be read", func() {
+			archive := &bytes.Buffer{}
+			gz := gzip.NewWriter(archive)
+			tw := tar.NewWriter(gz)
+			content := []byte(afterProcStatSample)
+			So(tw.WriteHeader(&tar.Header{Name: "sosreport-node-17/proc/stat", Mode: 0644, Size: int64(len(content)), ModTime: takenAt.Add(time.Minute)}), ShouldBeNil)
+			_, err := tw.Write(content)
+			So(err, ShouldBeNil)
+			So(tw.Close(), ShouldBeNil)
+			So(gz.Close(), ShouldBeNil)
+			path := filepath.Join(parent, "sosreport-node-17.tar.gz")
+			So(ioutil.WriteFile(path, archive.Bytes(), 0644), ShouldBeNil)
+
+			analyze([]string{"-before", before, "-after", path}, out)
+			So(out.String(), ShouldContainSubstring, "Percentages over 1m0s:")
+			// hostname, topology and cgroup usage are not captured in archive
+			So(out.String(), ShouldContainSubstring, "[FAILED] captures come from the same host")
+			So(out.String(), ShouldContainSubstring, "Topology: not captured\n")
+			So(out.String(), ShouldContainSubstring, "Cgroup usage of "+path+": not captured\n")
+		})
+
+		Convey("missing capture should be reported", func() {
+			So(analyze([]string{}, out), ShouldNotBeNil)
+			So(analyze([]string{"-before", filepath.Join(parent, "missing")}, out), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,184 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cpu/procstat"
+)
+
+const (
+	//baselineSaveInterval minimal time between persisted samples of task, so that files are not written
+	//in every collection; baseline restored after restart is at most this much older than the last sample
+	baselineSaveInterval = time.Minute
+
+	//maxBaselineAge age after which persisted baseline is discarded, e.g. of task which was removed
+	maxBaselineAge = 24 * time.Hour
+)
+
+//persistedBaseline the last sample of task persisted in state_dir, so that percentages are available
+//from the first collection after restart of plugin; it is valid only until the host reboots
+type persistedBaseline struct {
+	ProcPath  string          `json:"proc_path"`
+	BootTime  uint64          `json:"btime"`
+	StateKey  string          `json:"state_key"`
+	Timestamp time.Time       `json:"timestamp"`
+	Sample    procstat.Sample `json:"sample"`
+}
+
+//baselineStore directory where the last samples of tasks reading source are persisted
+type baselineStore struct {
+	dir      string
+	procPath string
+	bootTime uint64 // btime of host, baselines persisted before reboot have a different one
+}
+
+//newBaselineStore creates store of baselines of source in given directory, boot time is read from source;
+//baselines of source persisted before reboot of host or aged out are removed
+func newBaselineStore(dir string, filesystem FileSystem, procPath string) (*baselineStore, error) {
+	fh, err := filesystem.Open(procPath)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	bootTime, err := procstat.BootTime(fh)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read boot time from %s: %v", procPath, err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	store := &baselineStore{dir: dir, procPath: procPath, bootTime: bootTime}
+	if err := store.prune(readTime(filesystem)); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+//prune removes baselines of source which were persisted before reboot of host or are older than maxBaselineAge
+//at given time, corrupted baselines are removed too; baselines of other sources sharing directory are kept
+func (b *baselineStore) prune(now time.Time) error {
+	paths, err := filepath.Glob(filepath.Join(b.dir, "cpu-*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		baseline := persistedBaseline{}
+		if err := json.Unmarshal(content, &baseline); err != nil {
+			os.Remove(path)
+			continue
+		}
+		if baseline.ProcPath == b.procPath && (baseline.BootTime != b.bootTime || now.Sub(baseline.Timestamp) > maxBaselineAge) {
+			os.Remove(path)
+		}
+	}
+	return nil
+}
+
+//path returns path of file with baseline of task with given state key
+func (b *baselineStore) path(stateKey string) string {
+	sum := sha256.Sum256([]byte(b.procPath + "\x00" + stateKey))
+	return filepath.Join(b.dir, fmt.Sprintf("cpu-%x.json", sum[:16]))
+}
+
+//restore loads persisted baseline of task into its empty state, baselines persisted before reboot of host
+//or for other source or task are discarded; state is left empty when there is no valid baseline
+func (b *baselineStore) restore(stateKey string, state *sampleState) error {
+	path := b.path(stateKey)
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	baseline := persistedBaseline{}
+	if err := json.Unmarshal(content, &baseline); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("Cannot parse baseline %s: %v", path, err)
+	}
+	if baseline.ProcPath != b.procPath || baseline.BootTime != b.bootTime || baseline.StateKey != stateKey {
+		return os.Remove(path)
+	}
+	state.curr = baseline.Sample
+	state.currTime = baseline.Timestamp
+	state.savedTime = baseline.Timestamp
+	state.samples = 1
+	return nil
+}
+
+//due tells whether the current sample of task should be persisted, i.e. it was not persisted yet
+//and baselineSaveInterval elapsed since the previous persisted one
+func (b *baselineStore) due(state *sampleState) bool {
+	return state.samples > 0 && state.currTime.Sub(state.savedTime) >= baselineSaveInterval
+}
+
+//save persists the current sample of task, file is replaced atomically so that crash never leaves partial baseline
+func (b *baselineStore) save(stateKey string, state *sampleState) error {
+	content, err := json.Marshal(persistedBaseline{
+		ProcPath:  b.procPath,
+		BootTime:  b.bootTime,
+		StateKey:  stateKey,
+		Timestamp: state.currTime,
+		Sample:    state.curr,
+	})
+	if err != nil {
+		return err
+	}
+	path := b.path(stateKey)
+	tmp, err := ioutil.TempFile(b.dir, "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	state.savedTime = state.currTime
+	return nil
+}
+
+//remove removes persisted baseline of task, e.g. when its state was dropped
+func (b *baselineStore) remove(stateKey string) error {
+	if err := os.Remove(b.path(stateKey)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
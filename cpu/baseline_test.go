@@ -0,0 +1,162 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPersistedBaselines(t *testing.T) {
+	Convey("Given plugin persisting baselines in state_dir", t, func() {
+		stateDir, err := ioutil.TempDir("", "snap-plugin-collector-cpu")
+		So(err, ShouldBeNil)
+		Reset(func() { os.RemoveAll(stateDir) })
+		fixture := MapFS{"proc/stat": firstProcStatSample + "\nbtime 1479398400\n"}
+		cfg := plugin.Config{"proc_path": "/proc", "state_dir": stateDir}
+		requests := []plugin.Metric{
+			plugin.Metric{Namespace: plugin.NewNamespace(vendor, fs, pluginName, "0", "user_percentage"), Config: cfg},
+		}
+		mts, err := NewWithFileSystem(fixture).CollectMetrics(requests)
+		So(err, ShouldBeNil)
+		So(mts[0].Data, ShouldBeNil)
+		files, err := filepath.Glob(filepath.Join(stateDir, "cpu-*.json"))
+		So(err, ShouldBeNil)
+		So(len(files), ShouldEqual, 1)
+
+		Convey("percentages should be available in the first collection after restart", func() {
+			fixture["proc/stat"] = secondProcStatSample + "\nbtime 1479398400\n"
+			mts, err := NewWithFileSystem(fixture).CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			So(mts[0].Data, ShouldEqual, 25)
+		})
+
+		Convey("baseline persisted before reboot should be discarded", func() {
+			fixture["proc/stat"] = secondProcStatSample + "\nbtime 1479398999\n"
+			p := NewWithFileSystem(fixture)
+			src, err := p.getSource(cfg)
+			So(err, ShouldBeNil)
+			So(src.baselines.restore(getStateKey(requests), newSampleState()), ShouldBeNil)
+			_, err = os.Stat(files[0])
+			So(os.IsNotExist(err), ShouldBeTrue)
+
+			mts, err := p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			So(mts[0].Data, ShouldBeNil)
+		})
+
+		Convey("baselines of other tasks should not be used", func() {
+			fixture["proc/stat"] = secondProcStatSample + "\nbtime 1479398400\n"
+			cfg[taskIDConfigKey] = "other"
+			mts, err := NewWithFileSystem(fixture).CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			So(mts[0].Data, ShouldBeNil)
+			files, err := filepath.Glob(filepath.Join(stateDir, "cpu-*.json"))
+			So(err, ShouldBeNil)
+			So(len(files), ShouldEqual, 2)
+		})
+
+		Convey("corrupted baseline should be discarded", func() {
+			So(ioutil.WriteFile(files[0], []byte("{"), 0600), ShouldBeNil)
+			fixture["proc/stat"] = secondProcStatSample + "\nbtime 1479398400\n"
+			mts, err := NewWithFileSystem(fixture).CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			So(mts[0].Data, ShouldBeNil)
+		})
+
+		Convey("baselines should not be persisted in every collection", func() {
+			persisted, err := ioutil.ReadFile(files[0])
+			So(err, ShouldBeNil)
+			fixture["proc/stat"] = secondProcStatSample + "\nbtime 1479398400\n"
+			p := NewWithFileSystem(fixture)
+			_, err = p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			content, err := ioutil.ReadFile(files[0])
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, string(persisted))
+
+			//samples which were not persisted yet are persisted when plugin is closed
+			p.Close()
+			content, err = ioutil.ReadFile(files[0])
+			So(err, ShouldBeNil)
+			So(string(content), ShouldNotEqual, string(persisted))
+		})
+
+		Convey("baselines persisted before reboot or aged out should be removed when plugin starts", func() {
+			old := persistedBaseline{ProcPath: "/proc/stat", BootTime: 1479398400, StateKey: "old", Timestamp: time.Now().Add(-maxBaselineAge - time.Hour)}
+			content, err := json.Marshal(old)
+			So(err, ShouldBeNil)
+			agedOut := filepath.Join(stateDir, "cpu-aged.json")
+			So(ioutil.WriteFile(agedOut, content, 0600), ShouldBeNil)
+			other := old
+			other.ProcPath, other.Timestamp = "/hostproc/stat", time.Now()
+			content, err = json.Marshal(other)
+			So(err, ShouldBeNil)
+			otherSource := filepath.Join(stateDir, "cpu-other.json")
+			So(ioutil.WriteFile(otherSource, content, 0600), ShouldBeNil)
+
+			fixture["proc/stat"] = secondProcStatSample + "\nbtime 1479398999\n"
+			_, err = NewWithFileSystem(fixture).getSource(cfg)
+			So(err, ShouldBeNil)
+			for _, path := range []string{files[0], agedOut} {
+				_, err = os.Stat(path)
+				So(os.IsNotExist(err), ShouldBeTrue)
+			}
+			_, err = os.Stat(otherSource)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("baselines of dropped states should be removed", func() {
+			p := NewWithFileSystem(fixture)
+			_, err := p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			for i := 0; i < maxStatesNumber; i++ {
+				taskCfg := plugin.Config{"proc_path": "/proc", "state_dir": stateDir, taskIDConfigKey: strconv.Itoa(i)}
+				_, err := p.CollectMetrics([]plugin.Metric{plugin.Metric{Namespace: requests[0].Namespace, Config: taskCfg}})
+				So(err, ShouldBeNil)
+			}
+			_, err = os.Stat(files[0])
+			So(os.IsNotExist(err), ShouldBeTrue)
+			files, err := filepath.Glob(filepath.Join(stateDir, "cpu-*.json"))
+			So(err, ShouldBeNil)
+			So(len(files), ShouldEqual, maxStatesNumber)
+		})
+
+		Convey("collection should not depend on persistence", func() {
+			fixture["proc/stat"] = secondProcStatSample
+			p := NewWithFileSystem(fixture)
+			src, err := p.getSource(cfg)
+			So(err, ShouldBeNil)
+			So(src.baselines, ShouldBeNil)
+			_, err = p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+		})
+	})
+}
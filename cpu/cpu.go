@@ -23,18 +23,14 @@ limitations under the License.
 package cpu
 
 import (
-	"bufio"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strconv"
-	"strings"
+	"sync"
 	"time"
 
-	"github.com/intelsdi-x/snap/control/plugin"
-	"github.com/intelsdi-x/snap/control/plugin/cpolicy"
-	"github.com/intelsdi-x/snap/core"
-	"github.com/intelsdi-x/snap/core/ctypes"
+	"github.com/intelsdi-x/snap-plugin-collector-cpu/procstat"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
 )
 
 const (
@@ -48,10 +44,16 @@ const (
 	pluginName = "cpu"
 
 	// version of cpu plugin
-	version = 6
+	version = 7
 
-	//pluginType type of plugin
-	pluginType = plugin.CollectorPluginType
+	//PluginName name under which plugin is registered
+	PluginName = pluginName
+
+	//PluginVersion version under which plugin is registered
+	PluginVersion = version
+
+	//concurrencyCount number of tasks which may be served by plugin concurrently
+	concurrencyCount = 5
 
 	//userProcStat "user" metric from /proc/stat
 	userProcStat = "user"
@@ -95,36 +97,56 @@ const (
 	//percentageRepresentationType percentage representation type
 	percentageRepresentationType = "percentage"
 
+	//deltaRepresentationType representation as jiffies since the previous collection
+	deltaRepresentationType = "delta"
+
+	//rateRepresentationType representation as jiffies per second since the previous collection
+	rateRepresentationType = "rate"
+
+	//percentageAvgRepresentationType average of percentages sampled by background sampler
+	percentageAvgRepresentationType = "percentage_avg"
+
+	//percentageMaxRepresentationType maximum of percentages sampled by background sampler
+	percentageMaxRepresentationType = "percentage_max"
+
+	//percentageMinRepresentationType minimum of percentages sampled by background sampler
+	percentageMinRepresentationType = "percentage_min"
+
+	//percentageP95RepresentationType 95th percentile of percentages sampled by background sampler
+	percentageP95RepresentationType = "percentage_p95"
+
 	//maxNamespaceSize max size of namespace for metrics
 	maxNamespaceSize = 5
 
 	//allCPU string indentifier for aggregation metrics (for all CPUs)
 	allCPU = "all"
 
-	//cpuStr string indentifier for /proc/stat line which have desired CPU metrics
-	cpuStr = "cpu"
+	//activeColumn column given to metrics of snap specific active state, which is calculated from /proc/stat columns
+	activeColumn = -1
+
+	//utilizationColumn column given to metrics of snap specific utilization state
+	utilizationColumn = -2
+
+	//intervalMetricName name of metric reporting time between the previous and the current collection
+	intervalMetricName = "interval_seconds"
+
+	//unknownColumnPrefix prefix of generic name given to /proc/stat columns not known to plugin, e.g. column11
+	unknownColumnPrefix = "column"
 )
 
-//Plugin cpu plugin struct which gathers plugin specific data
+//procStatColumns names of CPU columns in /proc/stat in the order reported by kernel,
+//older kernels report only the leading part of this list
+var procStatColumns = []string{userProcStat, niceProcStat, systemProcStat, idleProcStat,
+	iowaitProcStat, irqProcStat, softirqProcStat, stealProcStat, guestProcStat, guestNiceProcStat}
 
-/* stats - metrics per cpu read from file /proc/stat:
-map ["all": map["user_jiffies": x
-		"user_percentage" x
-		... ]
-     "0": map["user_jiffies": x
-	      "user_percentage" x
-	      ... ]
-     "1": ... ]
-*/
+//Plugin cpu plugin struct which gathers plugin specific data,
+//each /proc/stat file requested by tasks is read by separate source which keeps baselines of these tasks
 type Plugin struct {
-	initialized          bool
-	proc_path            string
-	host                 string
-	cpuMetricsNumber     int // number of cpu + "all" metric
-	stats                map[string]map[string]interface{}
-	prevMetricsSum       map[string]float64
-	procStatMetricsNames []string
-	snapMetricsNames     []string
+	host         string
+	filesystem   FileSystem
+	mutex        sync.Mutex
+	sources      map[string]*source
+	sourcesClock uint64 // incremented on every use of source to find the least recently used one
 }
 
 //cpuInfo source of data for metrics
@@ -132,379 +154,456 @@ var cpuInfo = "/proc/stat"
 
 // GetMetricTypes returns list of available metric types
 // It returns error in case retrieval was not successful
-func (p *Plugin) GetMetricTypes(cfg plugin.ConfigType) ([]plugin.MetricType, error) {
-	if !p.initialized {
-		if err := p.init(cfg.Table()); err != nil {
-			return nil, err
-		}
-	}
-	if err := getStats(p.proc_path, p.stats, p.prevMetricsSum, p.cpuMetricsNumber,
-		p.snapMetricsNames, p.procStatMetricsNames); err != nil {
+func (p *Plugin) GetMetricTypes(cfg plugin.Config) ([]plugin.Metric, error) {
+	sources, _, err := p.getSources(cfg)
+	if err != nil {
 		return nil, err
 	}
-	metricTypes := []plugin.MetricType{}
-
-	namespaces := []string{}
 
-	prefix := filepath.Join(vendor, fs, pluginName)
-	for cpu, stats := range p.stats {
-		for metric, _ := range stats {
-			namespaces = append(namespaces, prefix+"/"+cpu+"/"+metric)
+	//CPU states available in any of sources, columns reported by kernel differ between hosts
+	stateNames := []string{}
+	seen := map[string]bool{}
+	sampled := false
+	windows := []string{}
+	seenWindows := map[string]bool{}
+	for _, src := range sources {
+		sampled = sampled || src.sampler != nil
+		for _, window := range src.rollingWindows {
+			if label := windowLabel(window); !seenWindows[label] {
+				seenWindows[label] = true
+				windows = append(windows, label)
+			}
 		}
-	}
-
-	// List of terminal metric names
-	mList := make(map[string]bool)
-	for _, namespace := range namespaces {
-		namespace = strings.TrimRight(namespace, string(os.PathSeparator))
-		metricType := plugin.MetricType{
-			Namespace_: core.NewNamespace(strings.Split(namespace, string(os.PathSeparator))...)}
-		ns := metricType.Namespace()
-		// CPU metric (aka last element in namespace)
-		mItem := ns[len(ns)-1]
-		// Keep it if not already seen before
-		if !mList[mItem.Value] {
-			mList[mItem.Value] = true
-			metricTypes = append(metricTypes, plugin.MetricType{
-				Namespace_: core.NewNamespace(strings.Split(prefix, string(os.PathSeparator))...).
-					AddDynamicElement("cpuID", "ID of CPU ('all' for aggregate)").
-					AddStaticElement(mItem.Value),
-				Description_: "dynamic CPU metric: " + mItem.Value,
-			})
+		for _, name := range src.snapMetricsNames {
+			if !seen[name] {
+				seen[name] = true
+				stateNames = append(stateNames, name)
+			}
 		}
 	}
 
+	metricTypes := []plugin.Metric{}
+	for _, descriptor := range getMetricsRegistry(stateNames, sampled, windows) {
+		metricTypes = append(metricTypes, plugin.Metric{
+			Namespace: plugin.NewNamespace(vendor, fs, pluginName).
+				AddDynamicElement("cpuID", "ID of CPU ('all' for aggregate, 'min', 'max', 'mean', 'stddev', 'cv' or 'max_cpu' for statistics of percentages across CPUs)").
+				AddStaticElement(descriptor.Name),
+			Version:     version,
+			Description: descriptor.Description,
+			Unit:        descriptor.Unit,
+			Tags:        map[string]string{kindTag: string(descriptor.Kind)},
+		})
+	}
 	return metricTypes, nil
 }
 
 // CollectMetrics returns list of requested metric values
 // It returns error in case retrieval was not successful
-func (p *Plugin) CollectMetrics(metricTypes []plugin.MetricType) ([]plugin.MetricType, error) {
-	metrics := []plugin.MetricType{}
-	if !p.initialized {
-		if err := p.init(metricTypes[0].Config().Table()); err != nil {
-			return nil, err
-		}
-	}
-	if err := getStats(p.proc_path, p.stats, p.prevMetricsSum, p.cpuMetricsNumber,
-		p.snapMetricsNames, p.procStatMetricsNames); err != nil {
-		return nil, err
-	}
-	ts := time.Now()
+func (p *Plugin) CollectMetrics(metricTypes []plugin.Metric) ([]plugin.Metric, error) {
+	metrics := []plugin.Metric{}
+
+	//group requested metrics by sources given in their config
+	requests := []*sourceRequest{}
 	for _, metricType := range metricTypes {
-		ns := metricType.Namespace()
-		if len(ns) != maxNamespaceSize {
-			return nil, fmt.Errorf("Incorrect namespace length (len = %d)", len(ns))
+		cfg := metricType.Config
+		sources, names, err := p.getSources(cfg)
+		if err != nil {
+			return nil, err
 		}
-		if ns[len(ns)-2].Value == "*" {
-			for cpuId, cpuStats := range p.stats {
-				for k, v := range cpuStats {
-					if ns[len(ns)-1].Value == k && v != nil {
-						ns1 := make([]core.NamespaceElement, len(ns))
-						copy(ns1, ns)
-						ns1[len(ns)-2].Value = cpuId
-						metric := plugin.MetricType{
-							Namespace_: ns1,
-							Data_:      v,
-							Timestamp_: ts,
-							Version_:   version,
-						}
-						metrics = append(metrics, metric)
-					}
-				}
-			}
-		} else {
-			val, err := getMapValueByNamespace(p.stats[ns.Strings()[3]], ns.Strings()[4:])
+		for i, src := range sources {
+			tags, err := p.getMetricTags(cfg, src, names[i])
 			if err != nil {
-				return metrics, err
+				return nil, err
 			}
-			metric := plugin.MetricType{
-				Namespace_: ns,
-				Data_:      val,
-				Timestamp_: ts,
-				Version_:   version,
+			//tags are passed along with request and copied to collected metrics
+			request := metricType
+			request.Tags = map[string]string{}
+			for key, value := range metricType.Tags {
+				request.Tags[key] = value
 			}
-			metrics = append(metrics, metric)
+			for key, value := range tags {
+				request.Tags[key] = value
+			}
+			requests = addSourceRequest(requests, src, names[i], request)
 		}
 	}
+
+	for _, request := range requests {
+		srcMetrics, err := request.src.collect(request.metricTypes)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, srcMetrics...)
+	}
 	return metrics, nil
 }
 
-// GetConfigPolicy returns config policy
-// It returns error in case retrieval was not successful
-func (p *Plugin) GetConfigPolicy() (*cpolicy.ConfigPolicy, error) {
-	cp := cpolicy.New()
-	rule, _ := cpolicy.NewStringRule("proc_path", false, "/proc")
-	node := cpolicy.NewPolicyNode()
-	node.Add(rule)
-	cp.Add([]string{vendor, fs, pluginName}, node)
-	return cp, nil
+//sourceRequest metrics requested from source, name is given when task collects from several procfs roots
+type sourceRequest struct {
+	src         *source
+	name        string
+	metricTypes []plugin.Metric
 }
 
-//Meta returns meta data for plugin
-func Meta() *plugin.PluginMeta {
-	return plugin.NewPluginMeta(
-		pluginName,
-		version,
-		pluginType,
-		[]string{},
-		[]string{plugin.SnapGOBContentType},
-		plugin.ConcurrencyCount(1))
+//addSourceRequest adds metric type to request for source with given name, creating the request if needed
+func addSourceRequest(requests []*sourceRequest, src *source, name string, metricType plugin.Metric) []*sourceRequest {
+	for _, request := range requests {
+		if request.src == src && request.name == name {
+			request.metricTypes = append(request.metricTypes, metricType)
+			return requests
+		}
+	}
+	return append(requests, &sourceRequest{src: src, name: name, metricTypes: []plugin.Metric{metricType}})
 }
 
-func (p *Plugin) init(cfg map[string]ctypes.ConfigValue) error {
-	if procPath, ok := cfg["proc_path"]; ok {
-		p.proc_path = procPath.(ctypes.ConfigValueStr).Value + "/stat"
+//collect returns values of metrics requested from source, percentages are calculated
+//against the previous sample taken for the same task, tags of requested metrics are copied to collected ones;
+//when baselines are persisted, percentages are available in the first collection after restart of plugin,
+//samples are persisted at most once per baselineSaveInterval
+func (s *source) collect(metricTypes []plugin.Metric) ([]plugin.Metric, error) {
+	key := getStateKey(metricTypes)
+	state := s.getState(key)
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	if state.observeCollection(readTime(s.filesystem)) {
+		fmt.Fprintf(os.Stderr, "Collections of %s at irregular intervals share baseline, tasks requesting the same metrics "+
+			"with the same config should set distinct task_id config items: %s\n", s.procPath, key)
 	}
-	fh, err := os.Open(p.proc_path)
-	if err != nil {
-		return err
+	if s.baselines == nil {
+		return s.collectState(state, metricTypes)
 	}
-	defer fh.Close()
+	if state.samples == 0 {
+		if err := s.baselines.restore(key, state); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot restore baseline of %s: %v\n", s.procPath, err)
+		}
+	}
+	samples := state.samples
+	metrics, err := s.collectState(state, metricTypes)
+	if err == nil && state.samples > samples && s.baselines.due(state) {
+		if err := s.baselines.save(key, state); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot persist baseline of %s: %v\n", s.procPath, err)
+		}
+	}
+	return metrics, err
+}
 
-	var procStatMetricsNumber int
-	p.cpuMetricsNumber, procStatMetricsNumber, err = getInitialProcStatData(p.proc_path)
+//collectState reads new sample of source into sampling baseline and calculates requested metrics from it,
+//only the parts of source needed by requested metrics are read and only requested values are calculated
+func (s *source) collectState(state *sampleState, metricTypes []plugin.Metric) ([]plugin.Metric, error) {
+	plan, err := s.planCollection(metricTypes)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	metrics := make([]plugin.Metric, 0, len(plan.metrics))
+	if len(plan.metrics) == 0 {
+		return metrics, nil
+	}
+	if err := state.read(s.filesystem, s.procPath, len(s.procStatMetricsNames), plan.perCPU); err != nil {
+		return nil, err
+	}
+	if err := s.warmUp(state, plan); err != nil {
+		return nil, err
+	}
+	if s.sampler != nil && plan.sampled {
+		s.sampler.mutex.Lock()
+		defer s.sampler.mutex.Unlock()
+		if err := s.sampler.take(); err != nil {
+			return nil, err
+		}
+		state.window = state.window[:0]
+		if state.sampledSeq > 0 {
+			state.window = s.sampler.window(state.sampledSeq, state.window)
+		}
+		state.sampledSeq = s.sampler.seq
+	}
+	ts := time.Now()
+	//selected positions of CPUs reported for "*" by selection, CPUs are selected once for all metrics
+	var selected map[*cpuSelection][]int
+	for _, planned := range plan.metrics {
+		metricType, ref := planned.metricType, planned.ref
+		if isDistributionStatistic(planned.cpuID) {
+			metric := newMetric(metricType, planned.cpuID, ref, state.distributionValue(ref, planned.cpuID), ts)
+			metric.Unit = distributionUnit(ref, planned.cpuID)
+			metrics = append(metrics, metric)
+			continue
+		}
+		if planned.cpuID != "*" {
+			i, id, ok := state.position(planned.cpuID)
+			if !ok {
+				return metrics, fmt.Errorf("Unknown CPU {%s}", planned.cpuID)
+			}
+			metrics = append(metrics, newMetric(metricType, planned.cpuID, ref, state.value(ref, i, id), ts))
+			continue
+		}
+		if value := state.value(ref, -1, -1); value != nil {
+			metrics = append(metrics, newMetric(metricType, allCPU, ref, value, ts))
+		}
+		if ref.repr == intervalMetricName {
+			//interval is the same for all CPUs
+			continue
+		}
+		if planned.selection == nil {
+			for i, cpu := range state.curr.CPUs {
+				if value := state.value(ref, i, cpu.ID); value != nil {
+					metrics = append(metrics, newMetric(metricType, strconv.Itoa(cpu.ID), ref, value, ts))
+				}
+			}
+			continue
+		}
+		positions, ok := selected[planned.selection]
+		if !ok {
+			if selected == nil {
+				selected = map[*cpuSelection][]int{}
+			}
+			positions = state.selectCPUs(planned.selection)
+			selected[planned.selection] = positions
+		}
+		for _, i := range positions {
+			id := state.curr.CPUs[i].ID
+			if value := state.value(ref, i, id); value != nil {
+				metrics = append(metrics, newMetric(metricType, strconv.Itoa(id), ref, value, ts))
+			}
+		}
 	}
+	if plan.rolling {
+		if state.rolling == nil {
+			state.rolling = newRollingHistory(s.rollingWindows)
+		}
+		state.rolling.add(&state.curr, state.currTime)
+	}
+	return metrics, nil
+}
 
-	//initialize metric names arrays
-	p.procStatMetricsNames = []string{userProcStat, niceProcStat, systemProcStat, idleProcStat,
-		iowaitProcStat, irqProcStat, softirqProcStat, stealProcStat, guestProcStat, guestNiceProcStat}[0:procStatMetricsNumber]
-	snapSpecificMetricsNames := []string{activeProcStat, utilizationProcStat}
-
-	//build snapMetricsNames to support different kernels
-	//var snapMetricsNames []string
-	p.snapMetricsNames = append(p.snapMetricsNames, p.procStatMetricsNames...)
-	p.snapMetricsNames = append(p.snapMetricsNames, snapSpecificMetricsNames...)
-	p.stats = make(map[string]map[string]interface{})
-	p.prevMetricsSum = make(map[string]float64)
-	p.initialized = true
-	return nil
+//newMetric returns collected metric of requested type for CPU with given identifier
+func newMetric(metricType plugin.Metric, cpuID string, ref metricRef, data interface{}, ts time.Time) plugin.Metric {
+	ns := metricType.Namespace
+	if ns[len(ns)-2].Value != cpuID {
+		ns = make(plugin.Namespace, len(metricType.Namespace))
+		copy(ns, metricType.Namespace)
+		ns[len(ns)-2].Value = cpuID
+	}
+	return plugin.Metric{
+		Namespace: ns,
+		Data:      data,
+		Timestamp: ts,
+		Version:   version,
+		Tags:      metricType.Tags,
+		Unit:      ref.unit,
+	}
+}
+
+// GetConfigPolicy returns config policy
+// It returns error in case retrieval was not successful
+func (p *Plugin) GetConfigPolicy() (plugin.ConfigPolicy, error) {
+	policy := plugin.NewConfigPolicy()
+	ns := []string{vendor, fs, pluginName}
+	policy.AddNewStringRule(ns, "proc_path", false, plugin.SetDefaultString("/proc"))
+	policy.AddNewStringRule(ns, "proc_paths", false)
+	policy.AddNewBoolRule(ns, "report_unknown_columns", false, plugin.SetDefaultBool(true))
+	policy.AddNewStringRule(ns, taskIDConfigKey, false)
+	policy.AddNewStringRule(ns, "hostname", false)
+	policy.AddNewBoolRule(ns, "hostname_from_root", false, plugin.SetDefaultBool(false))
+	policy.AddNewStringRule(ns, "tags", false)
+	policy.AddNewStringRule(ns, "sampling_interval", false)
+	policy.AddNewStringRule(ns, "rolling_windows", false)
+	policy.AddNewStringRule(ns, "warmup", false)
+	policy.AddNewStringRule(ns, "state_dir", false)
+	policy.AddNewStringRule(ns, "cpu_list", false)
+	policy.AddNewBoolRule(ns, "aggregates_only", false, plugin.SetDefaultBool(false))
+	policy.AddNewIntRule(ns, "top_cpus", false, plugin.SetDefaultInt(0), plugin.SetMinInt(0))
+	policy.AddNewIntRule(ns, "sampling_buffer", false, plugin.SetDefaultInt(defaultSamplingBuffer),
+		plugin.SetMinInt(2), plugin.SetMaxInt(maxSamplingBuffer))
+	return *policy, nil
+}
+
+//Meta returns options of plugin meta data passed to plugin.StartCollector
+func Meta() []plugin.MetaOpt {
+	return []plugin.MetaOpt{plugin.ConcurrencyCount(concurrencyCount)}
 }
 
 // New creates instance of interface info plugin
 func New() *Plugin {
+	return NewWithFileSystem(LiveFS)
+}
+
+//NewWithFileSystem creates instance of plugin which reads sources from given filesystem
+func NewWithFileSystem(filesystem FileSystem) *Plugin {
 	host, err := os.Hostname()
 	if err != nil {
 		host = "localhost"
 	}
 	p := &Plugin{
-		host:      host,
-		proc_path: cpuInfo,
+		host:       host,
+		filesystem: filesystem,
+		sources:    make(map[string]*source),
 	}
 	return p
 }
 
-//getStats gets metrics from /proc/stat output and calculates snap specific metrics
-func getStats(path string, stats map[string]map[string]interface{}, prevMetricsSum map[string]float64, cpuMetricsNumber int,
-	snapMetricsNames []string, procStatMetricsNames []string) (err error) {
-	fh, err := os.Open(path)
-	if err != nil {
-		return err
-	}
-	defer fh.Close()
+//metricRef way of calculating metric from CPU times, metrics of source are resolved once
+//so that collection neither builds nor parses metric names
+type metricRef struct {
+	name      string // e.g. user_percentage
+	unit      string
+	column    int           // position of /proc/stat column, activeColumn or utilizationColumn for snap specific metrics
+	repr      string        // representation calculated from CPU times, e.g. jiffies, or intervalMetricName
+	statistic string        // representation of statistic of sampled percentages, e.g. percentage_max, empty for other metrics
+	window    time.Duration // length of window of rolling average, zero for other metrics
+}
 
-	scanner := bufio.NewScanner(fh)
-	for i := 0; i < cpuMetricsNumber; i++ {
-		scanErr := scanner.Scan()
-		if !scanErr {
-			return fmt.Errorf("Wrong %s format", path)
+//getMetricRefs returns ways of calculating metrics of given CPU states keyed by metric name,
+//statistics of sampled percentages are included when sampled is set and rolling averages for given windows
+func getMetricRefs(snapMetricsNames []string, procStatMetricsNames []string, sampled bool, windows []time.Duration) map[string]metricRef {
+	refs := make(map[string]metricRef, len(snapMetricsNames)*len(representations)+1)
+	refs[intervalMetricName] = metricRef{name: intervalMetricName, unit: intervalMetricDescriptor.Unit, repr: intervalMetricName}
+	for _, stateName := range snapMetricsNames {
+		column := columnIndex(stateName, procStatMetricsNames)
+		switch stateName {
+		case activeProcStat:
+			column = activeColumn
+		case utilizationProcStat:
+			column = utilizationColumn
 		}
-		fields := strings.Fields(scanner.Text())
-
-		if len(fields) < 2 {
-			return fmt.Errorf("Wrong %s format", path)
+		for _, repr := range representations {
+			if repr.sampled && !sampled {
+				continue
+			}
+			descriptor := getMetricDescriptor(stateName, repr)
+			ref := metricRef{
+				name:   descriptor.Name,
+				unit:   descriptor.Unit,
+				column: column,
+				repr:   repr.name,
+			}
+			if repr.sampled {
+				ref.repr, ref.statistic = percentageRepresentationType, repr.name
+			}
+			refs[descriptor.Name] = ref
 		}
-
-		cpuID := strings.TrimSpace(fields[0])
-		if cpuID == cpuStr {
-			if err != nil {
-				return err
+		if !isRollingState(stateName) {
+			continue
+		}
+		for _, window := range windows {
+			descriptor := getMetricDescriptor(stateName, rollingRepresentation(windowLabel(window)))
+			refs[descriptor.Name] = metricRef{
+				name:   descriptor.Name,
+				unit:   descriptor.Unit,
+				column: column,
+				repr:   percentageRepresentationType,
+				window: window,
 			}
-			cpuID = allCPU //change CPU identifier for aggregation metrics
-		} else {
-			cpuID = strings.TrimPrefix(cpuID, cpuStr) //get number from CPU indentifier, for example if CPU identifier is cpu42 then 42 is get
 		}
-		metrics := fields[1:]
+	}
+	return refs
+}
 
-		if len(metrics) != len(procStatMetricsNames) {
-			return fmt.Errorf("Wrong data length. Expected {%d} is {%d}",
-				len(procStatMetricsNames), len(metrics))
+//value returns value of metric for current CPU times, percentages, deltas and rates are calculated
+//against previous times read given number of seconds before; they are nil when previous times are not known
+//or the value cannot be calculated, so that counters going back are never reported as negative values
+func (r metricRef) value(curr procstat.CPUTimes, prev *procstat.CPUTimes, seconds float64) interface{} {
+	if r.repr == jiffiesRepresentationType {
+		switch r.column {
+		case activeColumn:
+			return curr.Active()
+		case utilizationColumn:
+			return curr.Utilization()
 		}
-
-		//sum of new data in line
-		currDataSum, err := strTabSum(metrics)
-		if err != nil {
-			return err
+		return curr.Column(r.column)
+	}
+	if prev == nil {
+		return nil
+	}
+	switch r.repr {
+	case intervalMetricName:
+		if seconds > 0 {
+			return seconds
 		}
-
-		metricStats := make(map[string]interface{})
-		for j := range snapMetricsNames {
-
-			metricName := snapMetricsNames[j]
-			var currVal float64
-			//data collecting, there is an assumption that firstly metrics from /proc/stat/
-			//are gathered then snap specific metrics (e.g. active and utilization are calculated)
-			if metricName == activeProcStat {
-				idleVal, err := getMapFloatValueByNamespace(metricStats,
-					[]string{getNamespaceMetricPart(idleProcStat, jiffiesRepresentationType)})
-				if err != nil {
-					return err
-				}
-				currVal = currDataSum - idleVal
-			} else if metricName == utilizationProcStat {
-				nonActiveVal, err := getMapFloatValueByNamespace(metricStats,
-					[]string{getNamespaceMetricPart(idleProcStat, jiffiesRepresentationType)})
-				if err != nil {
-					return err
-				}
-
-				currVal = currDataSum - nonActiveVal
-
-				nonActiveVal, err = getMapFloatValueByNamespace(metricStats,
-					[]string{getNamespaceMetricPart(iowaitProcStat, jiffiesRepresentationType)})
-				if err != nil {
-					return err
-				}
-
-				currVal = currVal - nonActiveVal
-			} else {
-				currVal, err = strconv.ParseFloat(metrics[j], 64)
-				if err != nil {
-					return err
-				}
-			}
-
-			metricStats[getNamespaceMetricPart(metricName, percentageRepresentationType)] = nil
-
-			if mapKeyExists(cpuID, prevMetricsSum) {
-				diffSum := currDataSum - prevMetricsSum[cpuID]
-				if diffSum > 0 {
-					prevVal, err := getMapFloatValueByNamespace(stats[cpuID],
-						[]string{getNamespaceMetricPart(metricName, jiffiesRepresentationType)})
-					if err != nil {
-						return err
-					}
-
-					if percVal := float64(100 * (currVal - prevVal) / diffSum); percVal < 0 {
-						fmt.Fprintf(os.Stderr, "Percentage value of %v could not be calculated due to invalid data reported by /proc/stat\n", getNamespaceMetricPart(metricName, percentageRepresentationType))
-					} else {
-						metricStats[getNamespaceMetricPart(metricName, percentageRepresentationType)] = percVal
-					}
-				} else {
-					fmt.Fprintf(os.Stderr, "Percentage value of %v could not be calculated due to invalid data reported by /proc/stat\n", getNamespaceMetricPart(metricName, percentageRepresentationType))
-				}
-			}
-			metricStats[getNamespaceMetricPart(metricName, jiffiesRepresentationType)] = currVal
+	case deltaRepresentationType:
+		if diff := r.diff(curr.Delta(*prev)); diff >= 0 {
+			return uint64(diff)
+		}
+	case rateRepresentationType:
+		if diff := r.diff(curr.Delta(*prev)); diff >= 0 && seconds > 0 {
+			return float64(diff) / seconds
 		}
-		stats[cpuID] = metricStats
-		prevMetricsSum[cpuID] = currDataSum
+	default:
+		if percent, ok := r.percent(curr, *prev); ok {
+			return percent
+		}
+	}
+	if r.repr == percentageRepresentationType {
+		fmt.Fprintf(os.Stderr, "Percentage value of %v could not be calculated due to invalid data reported by /proc/stat\n", r.name)
+	} else {
+		fmt.Fprintf(os.Stderr, "Value of %v could not be calculated due to invalid data reported by /proc/stat\n", r.name)
 	}
 	return nil
 }
 
-//getNamespaceMetricPart builds part of namespace specific for metric and representation type
-func getNamespaceMetricPart(metricName string, representationType string) (s string) {
-	s = metricName + "_" + representationType
-	return s
+//paired tells whether value of metric is calculated against the previous sample of the same task
+func (r metricRef) paired() bool {
+	return r.repr != jiffiesRepresentationType && r.statistic == "" && r.window == 0
 }
 
-//mapKeyExists checks if element with given key exists in map
-func mapKeyExists(key string, m map[string]float64) bool {
-	var ret = false
-	if _, ok := m[key]; ok {
-		ret = true
-	}
-	return ret
+//percent returns percent of time spent in state of metric between previous and current CPU times,
+//it is not ok when percentage cannot be calculated due to invalid data
+func (r metricRef) percent(curr procstat.CPUTimes, prev procstat.CPUTimes) (float64, bool) {
+	delta := curr.Delta(prev)
+	return delta.Percent(r.diff(delta))
 }
 
-//strTabSum adds string data as float
-func strTabSum(metrics []string) (sum float64, err error) {
-	sum = 0
-	for i := range metrics {
-		val, err := strconv.ParseFloat(metrics[i], 64)
-		if err != nil {
-			return sum, err
-		}
-		sum += val
+//diff returns time spent in state of metric according to difference of CPU times
+func (r metricRef) diff(delta procstat.Delta) int64 {
+	switch r.column {
+	case activeColumn:
+		return delta.Active()
+	case utilizationColumn:
+		return delta.Utilization()
 	}
-	return sum, err
+	return delta.Column(r.column)
 }
 
-//getMapFloatValueByNamespace gets value as float from map by namespace given in array of strings
-func getMapFloatValueByNamespace(m map[string]interface{}, ns []string) (val float64, err error) {
-	var interfaceVal interface{}
-	interfaceVal, err = getMapValueByNamespace(m, ns)
-	if err != nil {
-		return val, err
-	}
-	var errBool bool
-	val, errBool = interfaceVal.(float64)
-	if !errBool {
-		return val, fmt.Errorf("Parsing error")
+//getProcStatMetricsNames returns names of given number of /proc/stat CPU columns,
+//columns unknown to plugin get generic names with their position, e.g. column11
+func getProcStatMetricsNames(columnsNumber int) []string {
+	names := make([]string, columnsNumber)
+	for i := range names {
+		if i < len(procStatColumns) {
+			names[i] = procStatColumns[i]
+		} else {
+			names[i] = unknownColumnPrefix + strconv.Itoa(i+1)
+		}
 	}
-	return val, err
+	return names
 }
 
-//getMapValueByNamespace gets value from map by namespace given in array of strings
-func getMapValueByNamespace(m map[string]interface{}, ns []string) (val interface{}, err error) {
-	if m == nil {
-		return nil, fmt.Errorf("Invalid (nil) value of argument m")
-	}
-
-	if len(ns) == 0 {
-		return nil, fmt.Errorf("Namespace length equal to zero")
-	}
-
-	current := ns[0]
-
-	if len(ns) == 1 {
-		if val, ok := m[current]; ok {
-			return val, err
+//columnIndex returns position of column with given name in /proc/stat CPU line or -1 if it is not reported
+func columnIndex(name string, procStatMetricsNames []string) int {
+	for i := range procStatMetricsNames {
+		if procStatMetricsNames[i] == name {
+			return i
 		}
-		return val, fmt.Errorf("Key does not exist in map {key %s}", current)
 	}
+	return -1
+}
 
-	if v, ok := m[current].(map[string]interface{}); ok {
-		val, err = getMapValueByNamespace(v, ns[1:])
-		return val, err
-	}
-	return val, err
+//getNamespaceMetricPart builds part of namespace specific for metric and representation type
+func getNamespaceMetricPart(metricName string, representationType string) (s string) {
+	s = metricName + "_" + representationType
+	return s
 }
 
-//getInitialProcStatData gets number of CPUs and number of metrics available in /proc/stat output
-func getInitialProcStatData(path string) (cpuMetricsNumber int, procStatMetricNumber int, err error) {
-	fh, err := os.Open(path)
+//getInitialProcStatData gets number of metrics available in /proc/stat output,
+//CPUs are not counted since they are read from each sample
+func getInitialProcStatData(filesystem FileSystem, path string) (procStatMetricNumber int, err error) {
+	fh, err := filesystem.Open(path)
 	if err != nil {
-		return cpuMetricsNumber, procStatMetricNumber, err
+		return procStatMetricNumber, err
 	}
 	defer fh.Close()
 
-	scanner := bufio.NewScanner(fh)
-	//read the first line to start the loop
-	scanErr := scanner.Scan()
-	if scanErr != true {
-		return cpuMetricsNumber, procStatMetricNumber, fmt.Errorf("Cannot read from %s", path)
-	}
-
-	procStatLine := strings.Fields(scanner.Text())
-	procStatMetricNumber = len(procStatLine) - 1 //without cpu identifier (e.g. cpu or cpu0)
-
-	cpuMetricsNumber = 0
-	for strings.Contains(procStatLine[0], cpuStr) {
-		//check line length, compare current length with length of the first line
-		//length of the first line = len(procStatMetricNumber) + CPU identifier
-		if len(procStatLine) != (procStatMetricNumber + 1) {
-			return cpuMetricsNumber, procStatMetricNumber, fmt.Errorf("Incorrect %s output", path)
-		}
-		cpuMetricsNumber++
-		//read the next line to be able to check loop condition
-		scanErr = scanner.Scan()
-		if scanErr != true {
-			break //no more lines to read
-		}
-		procStatLine = strings.Fields(scanner.Text())
+	sample, err := procstat.Parse(fh)
+	if err != nil {
+		return procStatMetricNumber, fmt.Errorf("Incorrect %s output: %v", path, err)
 	}
-	return cpuMetricsNumber, procStatMetricNumber, err
+	return sample.Columns, nil
 }
@@ -29,7 +29,6 @@ import (
 
 	"github.com/intelsdi-x/snap/control/plugin"
 	"github.com/intelsdi-x/snap/core"
-	"github.com/intelsdi-x/snap/core/ctypes"
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/stretchr/testify/suite"
 )
@@ -55,6 +54,10 @@ const (
 	defaultFormatCpuStatIndex = 0
 	narrowFormatCpuStatIndex  = 7
 	eightColumnCpuStatIndex   = 8
+	fourColumnCpuStatIndex    = 9
+	sevenColumnCpuStatIndex   = 10
+	elevenColumnCpuStatIndex  = 11
+	threeColumnCpuStatIndex   = 12
 )
 
 func (cis *CPUInfoSuite) SetupSuite() {
@@ -62,6 +65,11 @@ func (cis *CPUInfoSuite) SetupSuite() {
 	loadMockCPUInfo(0)
 }
 
+func (cis *CPUInfoSuite) SetupTest() {
+	//tests start with valid data set, sources read it when created
+	loadMockCPUInfo(0)
+}
+
 func (cis *CPUInfoSuite) TearDownSuite() {
 	removeMockCPUInfo()
 }
@@ -74,15 +82,19 @@ func TestGetStatsSuite(t *testing.T) {
 	suite.Run(t, &CPUInfoSuite{MockCPUInfo: "MockCPUInfo"})
 }
 
-func mockNew() *Plugin {
-	p := New()
-	emptyCfg := make(map[string]ctypes.ConfigValue)
-	p.init(emptyCfg)
+func mockNew() *legacyPlugin {
+	p := newLegacy()
 	So(p, ShouldNotBeNil)
-	So(p.snapMetricsNames, ShouldNotBeNil)
+	So(mockSource(p).snapMetricsNames, ShouldNotBeNil)
 	return p
 }
 
+func mockSource(p *legacyPlugin) *source {
+	src, err := p.getSource(map[string]interface{}{})
+	So(err, ShouldBeNil)
+	return src
+}
+
 func loadMockCPUInfo(dataSetNumber int) {
 	var content string
 	if dataSetNumber == 0 {
@@ -128,6 +140,22 @@ func loadMockCPUInfo(dataSetNumber int) {
 		content = `cpu 180401494 227200 18747745 3823269793 1561918 12082 2511349 0
 			cpu0 22541572 28113 2329501 477843628 173611 1735 315175 0
 			cpu1 23343161 22869 2630545 476714355 160618 1759 329698 0`
+	} else if dataSetNumber == fourColumnCpuStatIndex {
+		content = `cpu 180401494 227200 18747745 3823269793
+			cpu0 22541572 28113 2329501 477843628
+			cpu1 23343161 22869 2630545 476714355`
+	} else if dataSetNumber == sevenColumnCpuStatIndex {
+		content = `cpu 180401494 227200 18747745 3823269793 1561918 12082 2511349
+			cpu0 22541572 28113 2329501 477843628 173611 1735 315175
+			cpu1 23343161 22869 2630545 476714355 160618 1759 329698`
+	} else if dataSetNumber == elevenColumnCpuStatIndex {
+		content = `cpu 180401494 227200 18747745 3823269793 1561918 12082 2511349 0 0 0 4242
+			cpu0 22541572 28113 2329501 477843628 173611 1735 315175 0 0 0 421
+			cpu1 23343161 22869 2630545 476714355 160618 1759 329698 0 0 0 422`
+	} else if dataSetNumber == threeColumnCpuStatIndex { //unsupported data set, idle column is missing
+		content = `cpu 180401494 227200 18747745
+			cpu0 22541572 28113 2329501
+			cpu1 23343161 22869 2630545`
 	}
 
 	cpuInfoContent := []byte(content)
@@ -144,6 +172,7 @@ func (cis *CPUInfoSuite) TestGetMetricTypes() {
 	_ = plugin.ConfigType{}
 	Convey("Given cpu info plugin initialized", cis.T(), func() {
 		p := mockNew()
+		src := mockSource(p)
 		So(p, ShouldNotBeNil)
 		Convey("When one wants to get list of available metrics", func() {
 			mts, err := p.GetMetricTypes(plugin.ConfigType{})
@@ -153,10 +182,11 @@ func (cis *CPUInfoSuite) TestGetMetricTypes() {
 			})
 
 			Convey("Then list of metrics is returned", func() {
-				// Len mts = 24
-				// cpuMetricsNumber = 3
+				// Len mts = 49
+				// number of CPUs and all = 3
 				// len snapMetricsNames = 12
-				So(len(mts), ShouldEqual, len(p.snapMetricsNames)*2)
+				// jiffies, percentage, delta and rate of each state and interval_seconds
+				So(len(mts), ShouldEqual, len(src.snapMetricsNames)*4+1)
 
 				namespaces := []string{}
 				for _, m := range mts {
@@ -295,592 +325,594 @@ func (cis *CPUInfoSuite) TestCollectMetrics() {
 func (cis *CPUInfoSuite) TestgetCPUMetrics() {
 	Convey("Given cpu plugin initialized", cis.T(), func() {
 		p := mockNew()
+		src := mockSource(p)
+		st := newLegacyStats()
 		So(p, ShouldNotBeNil)
 		Convey("We want to check if metrics have proper value", func() {
 			//get new data set from /proc/stat
 
 			loadMockCPUInfo(0)
 
-			errStats := getStats(p.proc_path, p.stats, p.prevMetricsSum, p.cpuMetricsNumber, p.snapMetricsNames, p.procStatMetricsNames)
+			errStats := getStats(src, st)
 			So(errStats, ShouldBeNil)
 
 			//all
 			ns := core.NewNamespace(allCPU, getNamespaceMetricPart(userProcStat, jiffiesRepresentationType))
-			val, err := getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err := getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 23359837)
-			_, ok := val.(float64)
+			_, ok := val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(niceProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 6006716)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(systemProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 1209900)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(idleProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 402135131)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(iowaitProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 129307)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(irqProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 4)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(softirqProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 2156)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(stealProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(guestProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(guestNiceProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			//cpu0
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(userProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 3464284)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(niceProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 998669)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(systemProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 208226)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(idleProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 49355234)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(iowaitProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 57380)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(irqProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 3)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(softirqProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 422)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(stealProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(guestProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(guestNiceProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			//cpu1
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(userProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 3501681)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(niceProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 1012206)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(systemProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 189642)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(idleProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 49374240)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(iowaitProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 11620)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(irqProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(softirqProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 278)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(stealProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(guestProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(guestNiceProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(userProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(niceProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(systemProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(idleProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(iowaitProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(irqProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(softirqProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(stealProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(guestProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(guestNiceProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(userProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(niceProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(systemProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(idleProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(iowaitProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(irqProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(softirqProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(stealProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(guestProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(guestNiceProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(userProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(niceProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(systemProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(idleProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(iowaitProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(irqProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(softirqProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(stealProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(guestProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(guestNiceProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldBeNil)
 
 			//get new data set from /proc/stat
 			loadMockCPUInfo(1)
-			errStats = getStats(p.proc_path, p.stats, p.prevMetricsSum, p.cpuMetricsNumber, p.snapMetricsNames, p.procStatMetricsNames)
+			errStats = getStats(src, st)
 			So(errStats, ShouldBeNil)
 
 			//all
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(userProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 23472679)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(niceProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 6048986)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(systemProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 1215282)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(idleProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 403105970)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(iowaitProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 129312)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(irqProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 4)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(softirqProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 2158)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(stealProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(guestProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(guestNiceProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			//cpu0
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(userProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 3480506)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(niceProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 1005574)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(systemProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 209103)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(idleProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 49472588)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(iowaitProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 57381)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(irqProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 3)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(softirqProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 424)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(stealProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(guestProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(guestNiceProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			//cpu1
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(userProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 3516068)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(niceProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 1019269)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(systemProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 190413)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(idleProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 49493320)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(iowaitProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 11620)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(irqProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(softirqProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 278)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(stealProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(guestProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(guestNiceProcStat, jiffiesRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
-			_, ok = val.(float64)
+			_, ok = val.(uint64)
 			So(ok, ShouldBeTrue)
 
 			//all percentage
@@ -888,42 +920,42 @@ func (cis *CPUInfoSuite) TestgetCPUMetrics() {
 			prevAllSum = 23359837 + 6006716 + 1209900 + 402135131 + 129307 + 4 + 2156
 			currAllSum = 23472679 + 6048986 + 1215282 + 403105970 + 129312 + 4 + 2158
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(userProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 100*(23472679-23359837)/(currAllSum-prevAllSum))
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(niceProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 100*(6048986-6006716)/(currAllSum-prevAllSum))
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(systemProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 100*(1215282-1209900)/(currAllSum-prevAllSum))
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(idleProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 100*(403105970-402135131)/(currAllSum-prevAllSum))
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(iowaitProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 100*(129312-129307)/(currAllSum-prevAllSum))
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(irqProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
 
@@ -931,28 +963,28 @@ func (cis *CPUInfoSuite) TestgetCPUMetrics() {
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(softirqProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 100*(2158-2156)/(currAllSum-prevAllSum))
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(stealProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(guestProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(allCPU, getNamespaceMetricPart(guestNiceProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
 			_, ok = val.(float64)
@@ -963,68 +995,68 @@ func (cis *CPUInfoSuite) TestgetCPUMetrics() {
 			prevCPU0Sum = 3464284 + 998669 + 208226 + 49355234 + 57380 + 3 + 422
 			currCPU0Sum = 3480506 + 1005574 + 209103 + 49472588 + 57381 + 3 + 424
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(userProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 100*(3480506-3464284)/(currCPU0Sum-prevCPU0Sum))
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(niceProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 100*(1005574-998669)/(currCPU0Sum-prevCPU0Sum))
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(systemProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 100*(209103-208226)/(currCPU0Sum-prevCPU0Sum))
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(idleProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 100*(49472588-49355234)/(currCPU0Sum-prevCPU0Sum))
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(iowaitProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 100*(57381-57380)/(currCPU0Sum-prevCPU0Sum))
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(irqProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(softirqProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 100*(424-422)/(currCPU0Sum-prevCPU0Sum))
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(stealProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(guestProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(guestNiceProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
 			_, ok = val.(float64)
@@ -1035,70 +1067,70 @@ func (cis *CPUInfoSuite) TestgetCPUMetrics() {
 			prevCPU1Sum = 3501681 + 1012206 + 189642 + 49374240 + 11620 + 278
 			currCPU1Sum = 3516068 + 1019269 + 190413 + 49493320 + 11620 + 278
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(userProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 100*(3516068-3501681)/(currCPU1Sum-prevCPU1Sum))
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(niceProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 100*(1019269-1012206)/(currCPU1Sum-prevCPU1Sum))
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(systemProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 100*(190413-189642)/(currCPU1Sum-prevCPU1Sum))
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(idleProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 100*(49493320-49374240)/(currCPU1Sum-prevCPU1Sum))
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(iowaitProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(irqProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(softirqProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(stealProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(guestProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
 			_, ok = val.(float64)
 			So(ok, ShouldBeTrue)
 
 			ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(guestNiceProcStat, percentageRepresentationType))
-			val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+			val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 0)
 			_, ok = val.(float64)
@@ -1107,11 +1139,11 @@ func (cis *CPUInfoSuite) TestgetCPUMetrics() {
 			Convey("We want to check if metric value is nil instead of negative in case of incorrect (decreasing) values in /proc/stat", func() {
 
 				loadMockCPUInfo(1)
-				errStats = getStats(p.proc_path, p.stats, p.prevMetricsSum, p.cpuMetricsNumber, p.snapMetricsNames, p.procStatMetricsNames)
+				errStats = getStats(src, st)
 				So(errStats, ShouldBeNil)
 				//get new data set to check percentage calculation for incorrect (decreasing) values in /proc/stat
 				loadMockCPUInfo(2)
-				errStats = getStats(p.proc_path, p.stats, p.prevMetricsSum, p.cpuMetricsNumber, p.snapMetricsNames, p.procStatMetricsNames)
+				errStats = getStats(src, st)
 				So(errStats, ShouldBeNil)
 
 				//all percentage
@@ -1120,68 +1152,68 @@ func (cis *CPUInfoSuite) TestgetCPUMetrics() {
 				prevAllSum = 23472679 + 6048986 + 1215282 + 403105970 + 129312 + 4 + 2158
 				currAllSum = 23472670 + 6049996 + 1215282 + 403105970 + 129312 + 4 + 2158
 				ns = core.NewNamespace(allCPU, getNamespaceMetricPart(userProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldBeNil)
 
 				ns = core.NewNamespace(allCPU, getNamespaceMetricPart(niceProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 100*(6049996-6048986)/(currAllSum-prevAllSum))
 				_, ok = val.(float64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(allCPU, getNamespaceMetricPart(systemProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 0)
 				_, ok = val.(float64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(allCPU, getNamespaceMetricPart(idleProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 0)
 				_, ok = val.(float64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(allCPU, getNamespaceMetricPart(iowaitProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 0)
 				_, ok = val.(float64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(allCPU, getNamespaceMetricPart(irqProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 0)
 				_, ok = val.(float64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(allCPU, getNamespaceMetricPart(softirqProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 0)
 				_, ok = val.(float64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(allCPU, getNamespaceMetricPart(stealProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 0)
 				_, ok = val.(float64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(allCPU, getNamespaceMetricPart(guestProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 0)
 				_, ok = val.(float64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(allCPU, getNamespaceMetricPart(guestNiceProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 0)
 				_, ok = val.(float64)
@@ -1192,66 +1224,66 @@ func (cis *CPUInfoSuite) TestgetCPUMetrics() {
 				prevCPU0Sum = 3480506 + 1005574 + 209103 + 49472588 + 57381 + 3 + 424
 				currCPU0Sum = 3480508 + 1005570 + 209105 + 49472590 + 57390 + 3 + 430
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(userProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 100*(3480508-3480506)/(currCPU0Sum-prevCPU0Sum))
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(niceProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldBeNil)
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(systemProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 100*(209105-209103)/(currCPU0Sum-prevCPU0Sum))
 				_, ok = val.(float64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(idleProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 100*(49472590-49472588)/(currCPU0Sum-prevCPU0Sum))
 				_, ok = val.(float64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(iowaitProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 100*(57390-57381)/(currCPU0Sum-prevCPU0Sum))
 				_, ok = val.(float64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(irqProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 0)
 				_, ok = val.(float64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(softirqProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 100*(430-424)/(currCPU0Sum-prevCPU0Sum))
 				_, ok = val.(float64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(stealProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 0)
 				_, ok = val.(float64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(guestProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 0)
 				_, ok = val.(float64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(guestNiceProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 0)
 				_, ok = val.(float64)
@@ -1262,67 +1294,67 @@ func (cis *CPUInfoSuite) TestgetCPUMetrics() {
 				prevCPU1Sum = 3516068 + 1019269 + 190413 + 49493320 + 11620 + 0 + 278
 				currCPU1Sum = 3516060 + 1019260 + 190410 + 49493310 + 11610 + 0 + 270
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(userProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldBeNil)
 
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(niceProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldBeNil)
 
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(systemProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldBeNil)
 
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(idleProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldBeNil)
 
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(iowaitProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldBeNil)
 
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(irqProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldBeNil)
 
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(softirqProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldBeNil)
 
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(stealProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldBeNil)
 
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(guestProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldBeNil)
 
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(guestNiceProcStat, percentageRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldBeNil)
 			})
 
 			Convey("We want to test getStats function with incorrect data sets", func() {
 				loadMockCPUInfo(4)
-				errStats = getStats(p.proc_path, p.stats, p.prevMetricsSum, p.cpuMetricsNumber, p.snapMetricsNames, p.procStatMetricsNames)
+				errStats = getStats(src, st)
 				So(errStats, ShouldNotBeNil)
 
 				loadMockCPUInfo(5)
-				errStats = getStats(p.proc_path, p.stats, p.prevMetricsSum, p.cpuMetricsNumber, p.snapMetricsNames, p.procStatMetricsNames)
+				errStats = getStats(src, st)
 				So(errStats, ShouldNotBeNil)
 
 				loadMockCPUInfo(6)
-				errStats = getStats(p.proc_path, p.stats, p.prevMetricsSum, p.cpuMetricsNumber, p.snapMetricsNames, p.procStatMetricsNames)
+				errStats = getStats(src, st)
 				So(errStats, ShouldNotBeNil)
 			})
 		})
@@ -1332,150 +1364,101 @@ func (cis *CPUInfoSuite) TestgetCPUMetrics() {
 func (cis *CPUInfoSuite) TestgetInitialProcStatData() {
 	Convey("Given cpu plugin initialized", cis.T(), func() {
 		p := mockNew()
+		src := mockSource(p)
 		So(p, ShouldNotBeNil)
 		Convey("We want to check initial reading of /proc/stat", func() {
 			loadMockCPUInfo(1)
-			_, _, err := getInitialProcStatData(p.proc_path)
+			_, err := getInitialProcStatData(src.filesystem, src.procPath)
 			So(err, ShouldBeNil)
 			loadMockCPUInfo(2)
-			_, _, err = getInitialProcStatData(p.proc_path)
+			_, err = getInitialProcStatData(src.filesystem, src.procPath)
 			So(err, ShouldBeNil)
 			loadMockCPUInfo(3)
-			_, _, err = getInitialProcStatData(p.proc_path)
+			_, err = getInitialProcStatData(src.filesystem, src.procPath)
 			So(err, ShouldBeNil)
 			loadMockCPUInfo(4)
-			_, _, err = getInitialProcStatData(p.proc_path)
+			_, err = getInitialProcStatData(src.filesystem, src.procPath)
 			So(err, ShouldNotBeNil)
 			loadMockCPUInfo(5)
-			_, _, err = getInitialProcStatData(p.proc_path)
+			_, err = getInitialProcStatData(src.filesystem, src.procPath)
 			So(err, ShouldNotBeNil)
 		})
 	})
 }
 
-func (cis *CPUInfoSuite) TestgetMapFloatValueByNamespace() {
-	Convey("Given cpu plugin initialized", cis.T(), func() {
-		Convey("We want to check getting float value from nested map", func() {
-			//create map to test
-			subSubMap := make(map[string]interface{})
-			subSubMap["subSubMap1"] = 56.0
-			subSubMap["subSubMap2"] = "343.343"
-			subSubMap["subSubMap3"] = '$'
-			subSubMap["subSubMap4"] = 'a'
-
-			subMap := make(map[string]interface{})
-			subMap["subMap1"] = subSubMap
-
-			mainMap := make(map[string]interface{})
-			mainMap["mainMap1"] = subMap
-
-			ns := core.NewNamespace("mainMap1", "subMap1", "subSubMap1")
-			_, err := getMapFloatValueByNamespace(mainMap, ns.Strings())
-			So(err, ShouldBeNil)
-
-			ns = core.NewNamespace("mainMap1", "subMap1", "subSubMap2")
-			_, err = getMapFloatValueByNamespace(mainMap, ns.Strings())
-			So(err, ShouldNotBeNil)
-
-			ns = core.NewNamespace("mainMap1", "subMap1", "subSubMap3")
-			_, err = getMapFloatValueByNamespace(mainMap, ns.Strings())
-			So(err, ShouldNotBeNil)
-
-			ns = core.NewNamespace("mainMap1", "subMap1", "subSubMap4")
-			_, err = getMapFloatValueByNamespace(mainMap, ns.Strings())
-			So(err, ShouldNotBeNil)
-
-			ns = core.NewNamespace("mainMap12", "subMap12", "subSubMap1")
-			_, err = getMapFloatValueByNamespace(mainMap, ns.Strings())
-			So(err, ShouldNotBeNil)
-
-			ns = core.NewNamespace("mainMap1", "subMap12", "subSubMap1")
-			_, err = getMapFloatValueByNamespace(mainMap, ns.Strings())
-			So(err, ShouldNotBeNil)
-
-			ns = core.NewNamespace("mainMap1", "subMap12", "subSubMap12")
-			_, err = getMapFloatValueByNamespace(mainMap, ns.Strings())
-			So(err, ShouldNotBeNil)
-
-			var nss []string
-			_, err = getMapFloatValueByNamespace(mainMap, nss)
-			So(err, ShouldNotBeNil)
-
-		})
-	})
-}
-
 func (cis *CPUInfoSuite) TestReadingNarrowFormatStats() {
 	Convey("Given cpu plugin initialized with narrow  /stat format", cis.T(), func() {
 		loadMockCPUInfo(narrowFormatCpuStatIndex)
 		Convey("plugin should be initialized without issues", func() {
 			p := mockNew()
+			src := mockSource(p)
+			st := newLegacyStats()
 			So(p, ShouldNotBeNil)
 			Convey("correct values should be collected", func() {
-				errStats := getStats(p.proc_path, p.stats, p.prevMetricsSum, p.cpuMetricsNumber, p.snapMetricsNames, p.procStatMetricsNames)
+				errStats := getStats(src, st)
 				So(errStats, ShouldBeNil)
-				_ = getStats(p.proc_path, p.stats, p.prevMetricsSum, p.cpuMetricsNumber, p.snapMetricsNames, p.procStatMetricsNames)
+				_ = getStats(src, st)
 				ns := core.NewNamespace(firstCPU, getNamespaceMetricPart(userProcStat, jiffiesRepresentationType))
-				val, err := getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err := getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 22541572)
-				_, ok := val.(float64)
+				_, ok := val.(uint64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(niceProcStat, jiffiesRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 28113)
-				_, ok = val.(float64)
+				_, ok = val.(uint64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(systemProcStat, jiffiesRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 2329501)
-				_, ok = val.(float64)
+				_, ok = val.(uint64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(idleProcStat, jiffiesRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 477843628)
-				_, ok = val.(float64)
+				_, ok = val.(uint64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(iowaitProcStat, jiffiesRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 173611)
-				_, ok = val.(float64)
+				_, ok = val.(uint64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(irqProcStat, jiffiesRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 1735)
-				_, ok = val.(float64)
+				_, ok = val.(uint64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(softirqProcStat, jiffiesRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 315175)
-				_, ok = val.(float64)
+				_, ok = val.(uint64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(stealProcStat, jiffiesRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 0)
-				_, ok = val.(float64)
+				_, ok = val.(uint64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(guestProcStat, jiffiesRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 0)
-				_, ok = val.(float64)
+				_, ok = val.(uint64)
 				So(ok, ShouldBeTrue)
 			})
 		})
@@ -1491,67 +1474,69 @@ func (cis *CPUInfoSuite) TestReadingEightColumnStats() {
 		loadMockCPUInfo(eightColumnCpuStatIndex)
 		Convey("plugin should be initialized without issues", func() {
 			p := mockNew()
+			src := mockSource(p)
+			st := newLegacyStats()
 			So(p, ShouldNotBeNil)
 			Convey("metrics should be parsed without errors", func() {
-				errStats := getStats(p.proc_path, p.stats, p.prevMetricsSum, p.cpuMetricsNumber, p.snapMetricsNames, p.procStatMetricsNames)
+				errStats := getStats(src, st)
 				So(errStats, ShouldBeNil)
 			})
 			Convey("correct values should be collected", func() {
-				_ = getStats(p.proc_path, p.stats, p.prevMetricsSum, p.cpuMetricsNumber, p.snapMetricsNames, p.procStatMetricsNames)
+				_ = getStats(src, st)
 				ns := core.NewNamespace(secondCPU, getNamespaceMetricPart(userProcStat, jiffiesRepresentationType))
-				val, err := getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err := getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 23343161)
-				_, ok := val.(float64)
+				_, ok := val.(uint64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(niceProcStat, jiffiesRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 22869)
-				_, ok = val.(float64)
+				_, ok = val.(uint64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(systemProcStat, jiffiesRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 2630545)
-				_, ok = val.(float64)
+				_, ok = val.(uint64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(idleProcStat, jiffiesRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 476714355)
-				_, ok = val.(float64)
+				_, ok = val.(uint64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(iowaitProcStat, jiffiesRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 160618)
-				_, ok = val.(float64)
+				_, ok = val.(uint64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(irqProcStat, jiffiesRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 1759)
-				_, ok = val.(float64)
+				_, ok = val.(uint64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(softirqProcStat, jiffiesRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 329698)
-				_, ok = val.(float64)
+				_, ok = val.(uint64)
 				So(ok, ShouldBeTrue)
 
 				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(stealProcStat, jiffiesRepresentationType))
-				val, err = getMapValueByNamespace(p.stats[ns.Strings()[0]], ns.Strings()[1:])
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
 				So(err, ShouldBeNil)
 				So(val, ShouldEqual, 0)
-				_, ok = val.(float64)
+				_, ok = val.(uint64)
 				So(ok, ShouldBeTrue)
 			})
 		})
@@ -1561,3 +1546,153 @@ func (cis *CPUInfoSuite) TestReadingEightColumnStats() {
 		})
 	})
 }
+
+func (cis *CPUInfoSuite) TestReadingFourColumnStats() {
+	Convey("Given cpu plugin initialized with 4-column  /stat format", cis.T(), func() {
+		loadMockCPUInfo(fourColumnCpuStatIndex)
+		Convey("plugin should be initialized without issues", func() {
+			p := mockNew()
+			src := mockSource(p)
+			st := newLegacyStats()
+			So(p, ShouldNotBeNil)
+			So(src.procStatMetricsNames, ShouldResemble, []string{userProcStat, niceProcStat, systemProcStat, idleProcStat})
+			So(src.snapMetricsNames, ShouldResemble, []string{userProcStat, niceProcStat, systemProcStat, idleProcStat,
+				activeProcStat, utilizationProcStat})
+			Convey("correct values should be collected", func() {
+				errStats := getStats(src, st)
+				So(errStats, ShouldBeNil)
+
+				ns := core.NewNamespace(firstCPU, getNamespaceMetricPart(idleProcStat, jiffiesRepresentationType))
+				val, err := getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
+				So(err, ShouldBeNil)
+				So(val, ShouldEqual, 477843628)
+
+				// utilization is the same as active when iowait is not reported
+				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(activeProcStat, jiffiesRepresentationType))
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
+				So(err, ShouldBeNil)
+				So(val, ShouldEqual, 22541572+28113+2329501)
+
+				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(utilizationProcStat, jiffiesRepresentationType))
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
+				So(err, ShouldBeNil)
+				So(val, ShouldEqual, 22541572+28113+2329501)
+
+				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(iowaitProcStat, jiffiesRepresentationType))
+				_, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
+				So(err, ShouldNotBeNil)
+			})
+		})
+		Reset(func() {
+			// reset mock cpu stats to default-width format for other testcases
+			loadMockCPUInfo(defaultFormatCpuStatIndex)
+		})
+	})
+}
+
+func (cis *CPUInfoSuite) TestReadingSevenColumnStats() {
+	Convey("Given cpu plugin initialized with 7-column  /stat format", cis.T(), func() {
+		loadMockCPUInfo(sevenColumnCpuStatIndex)
+		Convey("plugin should be initialized without issues", func() {
+			p := mockNew()
+			src := mockSource(p)
+			st := newLegacyStats()
+			So(p, ShouldNotBeNil)
+			So(len(src.procStatMetricsNames), ShouldEqual, 7)
+			So(src.snapMetricsNames, ShouldNotContain, stealProcStat)
+			Convey("correct values should be collected", func() {
+				errStats := getStats(src, st)
+				So(errStats, ShouldBeNil)
+
+				ns := core.NewNamespace(secondCPU, getNamespaceMetricPart(softirqProcStat, jiffiesRepresentationType))
+				val, err := getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
+				So(err, ShouldBeNil)
+				So(val, ShouldEqual, 329698)
+
+				ns = core.NewNamespace(secondCPU, getNamespaceMetricPart(utilizationProcStat, jiffiesRepresentationType))
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
+				So(err, ShouldBeNil)
+				So(val, ShouldEqual, 23343161+22869+2630545+1759+329698)
+			})
+		})
+		Reset(func() {
+			// reset mock cpu stats to default-width format for other testcases
+			loadMockCPUInfo(defaultFormatCpuStatIndex)
+		})
+	})
+}
+
+func (cis *CPUInfoSuite) TestReadingElevenColumnStats() {
+	Convey("Given cpu plugin initialized with 11-column  /stat format", cis.T(), func() {
+		loadMockCPUInfo(elevenColumnCpuStatIndex)
+		Convey("unknown column should be reported as generic metric", func() {
+			p := mockNew()
+			src := mockSource(p)
+			st := newLegacyStats()
+			So(p, ShouldNotBeNil)
+			So(src.procStatMetricsNames[10], ShouldEqual, "column11")
+			So(src.snapMetricsNames, ShouldContain, "column11")
+			Convey("correct values should be collected", func() {
+				errStats := getStats(src, st)
+				So(errStats, ShouldBeNil)
+
+				ns := core.NewNamespace(firstCPU, getNamespaceMetricPart("column11", jiffiesRepresentationType))
+				val, err := getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
+				So(err, ShouldBeNil)
+				So(val, ShouldEqual, 421)
+
+				ns = core.NewNamespace(firstCPU, getNamespaceMetricPart(guestNiceProcStat, jiffiesRepresentationType))
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
+				So(err, ShouldBeNil)
+				So(val, ShouldEqual, 0)
+
+				ns = core.NewNamespace(allCPU, getNamespaceMetricPart(activeProcStat, jiffiesRepresentationType))
+				val, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
+				So(err, ShouldBeNil)
+				So(val, ShouldEqual, 180401494+227200+18747745+1561918+12082+2511349+4242)
+			})
+			Convey("metric types should include unknown column", func() {
+				mts, err := p.GetMetricTypes(plugin.ConfigType{})
+				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, len(src.snapMetricsNames)*4+1)
+			})
+		})
+		Convey("unknown column should be ignored when configured", func() {
+			p := New()
+			cfg := map[string]interface{}{"report_unknown_columns": false}
+			src, err := p.getSource(cfg)
+			So(err, ShouldBeNil)
+			st := newLegacyStats()
+			So(len(src.procStatMetricsNames), ShouldEqual, 11)
+			So(src.snapMetricsNames, ShouldNotContain, "column11")
+			So(src.snapMetricsNames, ShouldContain, guestNiceProcStat)
+
+			errStats := getStats(src, st)
+			So(errStats, ShouldBeNil)
+			ns := core.NewNamespace(firstCPU, getNamespaceMetricPart("column11", jiffiesRepresentationType))
+			_, err = getMapValueByNamespace(st.stats[ns.Strings()[0]], ns.Strings()[1:])
+			So(err, ShouldNotBeNil)
+		})
+		Reset(func() {
+			// reset mock cpu stats to default-width format for other testcases
+			loadMockCPUInfo(defaultFormatCpuStatIndex)
+		})
+	})
+}
+
+func (cis *CPUInfoSuite) TestReadingUnsupportedStats() {
+	Convey("Given /stat format without idle column", cis.T(), func() {
+		loadMockCPUInfo(threeColumnCpuStatIndex)
+		Convey("plugin should report error instead of crashing", func() {
+			p := New()
+			emptyCfg := make(map[string]interface{})
+			So(func() { p.getSource(emptyCfg) }, ShouldNotPanic)
+			_, err := p.getSource(emptyCfg)
+			So(err, ShouldNotBeNil)
+		})
+		Reset(func() {
+			// reset mock cpu stats to default-width format for other testcases
+			loadMockCPUInfo(defaultFormatCpuStatIndex)
+		})
+	})
+}
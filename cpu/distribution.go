@@ -0,0 +1,117 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import "math"
+
+const (
+	//distributionMin minimum of percentage across CPUs
+	distributionMin = "min"
+
+	//distributionMax maximum of percentage across CPUs
+	distributionMax = "max"
+
+	//distributionMean mean of percentage across CPUs
+	distributionMean = "mean"
+
+	//distributionStddev population standard deviation of percentage across CPUs
+	distributionStddev = "stddev"
+
+	//distributionCV coefficient of variation of percentage across CPUs, i.e. its standard deviation divided by mean
+	distributionCV = "cv"
+
+	//distributionMaxCPU ID of CPU with maximum of percentage, i.e. the busiest one for utilization and active percentages
+	distributionMaxCPU = "max_cpu"
+)
+
+//distributionStatistics synthetic CPU IDs of statistics of percentages across CPUs
+var distributionStatistics = []string{
+	distributionMin, distributionMax, distributionMean, distributionStddev, distributionCV, distributionMaxCPU,
+}
+
+//isDistributionStatistic tells whether CPU ID is synthetic one of statistic across CPUs
+func isDistributionStatistic(cpuID string) bool {
+	for _, statistic := range distributionStatistics {
+		if cpuID == statistic {
+			return true
+		}
+	}
+	return false
+}
+
+//distributionValue returns statistic of percentage across CPUs of current sample,
+//CPUs whose percentage is not known are skipped and value is nil when it is not known for any CPU
+//or coefficient of variation is requested while mean is zero
+func (st *sampleState) distributionValue(ref metricRef, statistic string) interface{} {
+	st.spread = st.spread[:0]
+	var min, max, sum float64
+	maxCPU := 0
+	for i, cpu := range st.curr.CPUs {
+		value, ok := st.value(ref, i, cpu.ID).(float64)
+		if !ok {
+			continue
+		}
+		if len(st.spread) == 0 || value < min {
+			min = value
+		}
+		if len(st.spread) == 0 || value > max {
+			max, maxCPU = value, cpu.ID
+		}
+		sum += value
+		st.spread = append(st.spread, value)
+	}
+	if len(st.spread) == 0 {
+		return nil
+	}
+	mean := sum / float64(len(st.spread))
+	switch statistic {
+	case distributionMin:
+		return min
+	case distributionMax:
+		return max
+	case distributionMaxCPU:
+		return maxCPU
+	case distributionMean:
+		return mean
+	}
+	var squares float64
+	for _, value := range st.spread {
+		squares += (value - mean) * (value - mean)
+	}
+	stddev := math.Sqrt(squares / float64(len(st.spread)))
+	if statistic == distributionStddev {
+		return stddev
+	}
+	if mean == 0 {
+		return nil
+	}
+	return stddev / mean
+}
+
+//distributionUnit returns unit of statistic of percentage across CPUs, coefficient of variation
+//and ID of CPU are dimensionless
+func distributionUnit(ref metricRef, statistic string) string {
+	if statistic == distributionCV || statistic == distributionMaxCPU {
+		return ""
+	}
+	return ref.unit
+}
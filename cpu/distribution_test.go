@@ -0,0 +1,113 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"math"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	firstSpreadProcStatSample = `cpu  400 0 400 3200 0 0 0 0 0 0
+cpu0 100 0 100 800 0 0 0 0 0 0
+cpu1 100 0 100 800 0 0 0 0 0 0
+cpu2 100 0 100 800 0 0 0 0 0 0
+cpu3 100 0 100 800 0 0 0 0 0 0`
+
+	secondSpreadProcStatSample = `cpu  600 0 400 3400 0 0 0 0 0 0
+cpu0 100 0 100 900 0 0 0 0 0 0
+cpu1 150 0 100 850 0 0 0 0 0 0
+cpu2 200 0 100 800 0 0 0 0 0 0
+cpu3 150 0 100 850 0 0 0 0 0 0`
+
+	thirdSpreadProcStatSample = `cpu  700 0 400 3700 0 0 0 0 0 0
+cpu0 200 0 100 900 0 0 0 0 0 0
+cpu1 150 0 100 950 0 0 0 0 0 0
+cpu2 200 0 100 900 0 0 0 0 0 0
+cpu3 150 0 100 950 0 0 0 0 0 0`
+)
+
+func TestDistributionStatistics(t *testing.T) {
+	Convey("Given CPUs with user percentages 0, 50, 100 and 50", t, func() {
+		fixture := MapFS{"proc/stat": firstSpreadProcStatSample}
+		p := NewWithFileSystem(fixture)
+		cfg := plugin.Config{"proc_path": "/proc"}
+		request := func(cpuID string, name string) plugin.Metric {
+			return plugin.Metric{Namespace: plugin.NewNamespace(vendor, fs, pluginName, cpuID, name), Config: cfg}
+		}
+		requests := []plugin.Metric{}
+		for _, statistic := range distributionStatistics {
+			requests = append(requests, request(statistic, "user_percentage"))
+		}
+		requests = append(requests, request(distributionCV, "system_percentage"), request(distributionStddev, "system_percentage"))
+		requests = append(requests, request("*", "user_percentage"))
+		mts, err := p.CollectMetrics(requests)
+		So(err, ShouldBeNil)
+		So(len(mts), ShouldEqual, len(requests)-1)
+		for _, mt := range mts {
+			So(mt.Data, ShouldBeNil)
+		}
+		fixture["proc/stat"] = secondSpreadProcStatSample
+		mts, err = p.CollectMetrics(requests)
+		So(err, ShouldBeNil)
+		So(len(mts), ShouldEqual, len(requests)-1+5)
+
+		Convey("statistics across CPUs should be returned under their synthetic CPU IDs", func() {
+			values := map[string]interface{}{}
+			for _, mt := range mts[:len(distributionStatistics)] {
+				So(mt.Namespace[len(mt.Namespace)-1].Value, ShouldEqual, "user_percentage")
+				values[mt.Namespace[len(mt.Namespace)-2].Value] = mt.Data
+			}
+			So(values[distributionMin], ShouldEqual, 0)
+			So(values[distributionMax], ShouldEqual, 100)
+			So(values[distributionMean], ShouldEqual, 50)
+			So(values[distributionStddev], ShouldAlmostEqual, math.Sqrt(1250))
+			So(values[distributionCV], ShouldAlmostEqual, math.Sqrt(1250)/50)
+			So(values[distributionMaxCPU], ShouldEqual, 2)
+		})
+
+		Convey("dimensionless statistics should have no unit", func() {
+			So(mts[0].Unit, ShouldEqual, percentUnit)
+			So(mts[4].Unit, ShouldEqual, "")
+			So(mts[5].Unit, ShouldEqual, "")
+		})
+
+		Convey("coefficient of variation should not be reported when mean is zero", func() {
+			So(mts[6].Data, ShouldBeNil)
+			So(mts[7].Data, ShouldEqual, 0)
+		})
+
+		Convey("statistics should not be included in metrics of all CPUs", func() {
+			for i, mt := range mts[len(requests)-1:] {
+				So(mt.Namespace[len(mt.Namespace)-2].Value, ShouldEqual, []string{allCPU, "0", "1", "2", "3"}[i])
+			}
+		})
+
+		Convey("statistics of values other than percentages should be reported as error", func() {
+			_, err := p.CollectMetrics([]plugin.Metric{request(distributionMax, "user_jiffies")})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,102 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//FileSystem read-only filesystem which sources of plugin are read from,
+//it lets live procfs, captured root directory, archive or in-memory fixture back the plugin
+type FileSystem interface {
+	//Open opens file with given name for reading
+	Open(name string) (io.ReadCloser, error)
+}
+
+//Clock is implemented by filesystems whose files are not live, e.g. replayed sessions, it tells time
+//at which the current contents of files were read; wall-clock time is used for other filesystems
+type Clock interface {
+	//Now returns time at which the current contents of files were read
+	Now() time.Time
+}
+
+//readTime returns time at which files of filesystem are read
+func readTime(filesystem FileSystem) time.Time {
+	if clock, ok := filesystem.(Clock); ok {
+		return clock.Now()
+	}
+	return time.Now()
+}
+
+//LiveFS filesystem of machine plugin runs on, names are paths of operating system
+var LiveFS FileSystem = liveFS{}
+
+//liveFS implementation of LiveFS
+type liveFS struct{}
+
+//Open opens file of operating system
+func (liveFS) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+//DirFS filesystem rooted at given directory, e.g. unpacked capture of machine, names are relative to the root
+//even if they are absolute, so /proc/stat is read from <root>/proc/stat
+type DirFS string
+
+//Open opens file under root directory
+func (d DirFS) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), filepath.FromSlash(cleanName(name))))
+}
+
+//MapFS in-memory filesystem with contents of files keyed by slash-separated path relative to root, e.g. proc/stat,
+//leading slash of opened names is ignored
+type MapFS map[string]string
+
+//Open opens in-memory file
+func (m MapFS) Open(name string) (io.ReadCloser, error) {
+	content, ok := m[cleanName(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+//cleanName returns name as slash-separated path relative to root of filesystem
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
+
+//readFile reads whole file from filesystem
+func readFile(filesystem FileSystem, name string) ([]byte, error) {
+	f, err := filesystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
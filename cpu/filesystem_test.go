@@ -0,0 +1,150 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFileSystems(t *testing.T) {
+	Convey("Given directory with proc directory", t, func() {
+		root, err := ioutil.TempDir("", "snap-plugin-collector-cpu")
+		So(err, ShouldBeNil)
+		Reset(func() { os.RemoveAll(root) })
+		So(os.Mkdir(filepath.Join(root, "proc"), 0755), ShouldBeNil)
+		writeProcStat(filepath.Join(root, "proc"), firstProcStatSample)
+
+		Convey("directory filesystem should read names relative to its root", func() {
+			for _, name := range []string{"/proc/stat", "proc/stat", "/../proc/stat"} {
+				content, err := readFile(DirFS(root), name)
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, firstProcStatSample)
+			}
+			_, err := readFile(DirFS(root), "/etc/hostname")
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("live filesystem should read paths of operating system", func() {
+			content, err := readFile(LiveFS, filepath.Join(root, "proc", "stat"))
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, firstProcStatSample)
+		})
+	})
+
+	Convey("Given in-memory filesystem", t, func() {
+		filesystem := MapFS{"proc/stat": firstProcStatSample, "etc/hostname": "node-17\n"}
+
+		Convey("files should be read regardless of leading slash", func() {
+			content, err := readFile(filesystem, "/proc/stat")
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, firstProcStatSample)
+			_, err = readFile(filesystem, "/proc/uptime")
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("plugin should be backed by it", func() {
+			p := NewWithFileSystem(filesystem)
+			mts, err := p.CollectMetrics([]plugin.Metric{
+				plugin.Metric{
+					Namespace: plugin.NewNamespace(vendor, fs, pluginName, allCPU, getNamespaceMetricPart(userProcStat, jiffiesRepresentationType)),
+					Config:    plugin.Config{"proc_path": "/proc", "hostname_from_root": true},
+				},
+			})
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 1)
+			So(mts[0].Data, ShouldEqual, 100)
+			So(mts[0].Tags[hostnameTag], ShouldEqual, "node-17")
+		})
+	})
+}
+
+func TestInMemoryFixtures(t *testing.T) {
+	t.Parallel()
+	Convey("Given plugins backed by separate in-memory fixtures", t, func() {
+		samples := []string{firstProcStatSample, secondProcStatSample, hostProcStatSample, containerProcStatSample}
+
+		Convey("they should collect concurrently without sharing mock files", func() {
+			var wg sync.WaitGroup
+			values := make([]interface{}, len(samples))
+			errs := make([]error, len(samples))
+			for i, sample := range samples {
+				wg.Add(1)
+				go func(i int, sample string) {
+					defer wg.Done()
+					p := NewWithFileSystem(MapFS{"proc/stat": sample})
+					mts, err := p.CollectMetrics([]plugin.Metric{
+						plugin.Metric{
+							Namespace: plugin.NewNamespace(vendor, fs, pluginName, allCPU, getNamespaceMetricPart(systemProcStat, jiffiesRepresentationType)),
+							Config:    plugin.Config{"proc_path": "/proc"},
+						},
+					})
+					errs[i] = err
+					if err == nil {
+						values[i] = mts[0].Data
+					}
+				}(i, sample)
+			}
+			wg.Wait()
+			for _, err := range errs {
+				So(err, ShouldBeNil)
+			}
+			So(values, ShouldResemble, []interface{}{uint64(100), uint64(150), uint64(100), uint64(300)})
+		})
+	})
+}
+
+func TestLargeCounters(t *testing.T) {
+	Convey("Given in-memory fixture of long-running host with counters above 2^53", t, func() {
+		fixture := MapFS{"proc/stat": "cpu  9007199254740993 0 0 9007199254740993 1 0 0 0 0 0\n"}
+		p := NewWithFileSystem(fixture)
+		collect := func(name string) interface{} {
+			mts, err := p.CollectMetrics([]plugin.Metric{
+				plugin.Metric{
+					Namespace: plugin.NewNamespace(vendor, fs, pluginName, allCPU, name),
+					Config:    plugin.Config{"proc_path": "/proc"},
+				},
+			})
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 1)
+			return mts[0].Data
+		}
+
+		Convey("jiffies should be published as precise integers", func() {
+			So(collect("user_jiffies"), ShouldEqual, uint64(9007199254740993))
+			So(collect("active_jiffies"), ShouldEqual, uint64(9007199254740994))
+			So(collect("utilization_jiffies"), ShouldEqual, uint64(9007199254740993))
+		})
+
+		Convey("percentages should be calculated from integer differences", func() {
+			So(collect("user_percentage"), ShouldBeNil)
+			fixture["proc/stat"] = "cpu  9007199254740994 0 0 9007199254740996 1 0 0 0 0 0\n"
+			So(collect("user_percentage"), ShouldEqual, 25)
+		})
+	})
+}
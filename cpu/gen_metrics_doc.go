@@ -0,0 +1,43 @@
+// +build ignore
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//gen_metrics_doc regenerates METRICS.md from the metric registry, run it with go generate ./cpu
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cpu/cpu"
+)
+
+func main() {
+	fh, err := os.Create("../METRICS.md")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer fh.Close()
+	if err := cpu.WriteMetricsDoc(fh); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,183 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"fmt"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	snap "github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+//legacyPlugin exposes Plugin through deprecated control/plugin interfaces which CPUInfoSuite is written against
+type legacyPlugin struct {
+	*Plugin
+}
+
+//newLegacy creates instance of plugin wrapped in legacy interfaces
+func newLegacy() *legacyPlugin {
+	return &legacyPlugin{Plugin: New()}
+}
+
+//GetMetricTypes returns list of available metric types as legacy metric types
+func (l *legacyPlugin) GetMetricTypes(cfg snap.ConfigType) ([]snap.MetricType, error) {
+	mts, err := l.Plugin.GetMetricTypes(fromLegacyConfig(cfg.ConfigDataNode))
+	if err != nil {
+		return nil, err
+	}
+	return toLegacyMetrics(mts), nil
+}
+
+//CollectMetrics returns list of requested metric values as legacy metric types
+func (l *legacyPlugin) CollectMetrics(metricTypes []snap.MetricType) ([]snap.MetricType, error) {
+	requests := make([]plugin.Metric, len(metricTypes))
+	for i, mt := range metricTypes {
+		ns := make(plugin.Namespace, len(mt.Namespace()))
+		for j, element := range mt.Namespace() {
+			ns[j] = plugin.NamespaceElement{Value: element.Value, Name: element.Name, Description: element.Description}
+		}
+		requests[i] = plugin.Metric{
+			Namespace: ns,
+			Version:   int64(mt.Version()),
+			Config:    fromLegacyConfig(mt.Config()),
+			Tags:      mt.Tags(),
+		}
+	}
+	mts, err := l.Plugin.CollectMetrics(requests)
+	if err != nil {
+		return nil, err
+	}
+	return toLegacyMetrics(mts), nil
+}
+
+//fromLegacyConfig converts legacy config node to typed config, it is empty when no config was given
+func fromLegacyConfig(node *cdata.ConfigDataNode) plugin.Config {
+	cfg := plugin.Config{}
+	if node == nil {
+		return cfg
+	}
+	for key, value := range node.Table() {
+		switch v := value.(type) {
+		case ctypes.ConfigValueStr:
+			cfg[key] = v.Value
+		case ctypes.ConfigValueInt:
+			cfg[key] = int64(v.Value)
+		case ctypes.ConfigValueFloat:
+			cfg[key] = v.Value
+		case ctypes.ConfigValueBool:
+			cfg[key] = v.Value
+		}
+	}
+	return cfg
+}
+
+//toLegacyMetrics converts metrics to legacy metric types
+func toLegacyMetrics(mts []plugin.Metric) []snap.MetricType {
+	metricTypes := make([]snap.MetricType, len(mts))
+	for i, mt := range mts {
+		ns := make(core.Namespace, len(mt.Namespace))
+		for j, element := range mt.Namespace {
+			ns[j] = core.NamespaceElement{Value: element.Value, Name: element.Name, Description: element.Description}
+		}
+		metricTypes[i] = snap.MetricType{
+			Namespace_:   ns,
+			Data_:        mt.Data,
+			Timestamp_:   mt.Timestamp,
+			Version_:     int(mt.Version),
+			Tags_:        mt.Tags,
+			Unit_:        mt.Unit,
+			Description_: mt.Description,
+		}
+	}
+	return metricTypes
+}
+
+//legacyStats sampling baseline of CPUInfoSuite, which checks values of all metrics of source
+//kept in nested map by CPU identifier and metric name
+type legacyStats struct {
+	*sampleState
+	stats map[string]map[string]interface{}
+}
+
+//newLegacyStats creates empty sampling baseline with empty map of values
+func newLegacyStats() *legacyStats {
+	return &legacyStats{sampleState: newSampleState(), stats: map[string]map[string]interface{}{}}
+}
+
+//getStats collects all metrics of source for all CPUs and stores their values in stats,
+//percentages which are not available are stored as nil
+func getStats(src *source, st *legacyStats) error {
+	requests := []plugin.Metric{}
+	for name := range src.metrics {
+		requests = append(requests, plugin.Metric{Namespace: plugin.NewNamespace(vendor, fs, pluginName, "*", name)})
+	}
+	mts, err := src.collectState(st.sampleState, requests)
+	if err != nil {
+		return err
+	}
+	stats := map[string]map[string]interface{}{}
+	for _, mt := range mts {
+		cpuID := mt.Namespace[3].Value
+		if stats[cpuID] == nil {
+			stats[cpuID] = map[string]interface{}{}
+		}
+		stats[cpuID][mt.Namespace[4].Value] = mt.Data
+	}
+	for cpuID, cpuStats := range stats {
+		for name, ref := range src.metrics {
+			if _, ok := cpuStats[name]; !ok && ref.repr != jiffiesRepresentationType {
+				cpuStats[name] = nil
+			}
+		}
+		st.stats[cpuID] = cpuStats
+	}
+	return nil
+}
+
+//getMapValueByNamespace gets value from map by namespace given in array of strings
+func getMapValueByNamespace(m map[string]interface{}, ns []string) (val interface{}, err error) {
+	if m == nil {
+		return nil, fmt.Errorf("Invalid (nil) value of argument m")
+	}
+
+	if len(ns) == 0 {
+		return nil, fmt.Errorf("Namespace length equal to zero")
+	}
+
+	current := ns[0]
+
+	if len(ns) == 1 {
+		if val, ok := m[current]; ok {
+			return val, err
+		}
+		return val, fmt.Errorf("Key does not exist in map {key %s}", current)
+	}
+
+	if v, ok := m[current].(map[string]interface{}); ok {
+		val, err = getMapValueByNamespace(v, ns[1:])
+		return val, err
+	}
+	return val, err
+}
@@ -0,0 +1,249 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//go:generate go run gen_metrics_doc.go
+
+package cpu
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+//MetricKind tells whether metric value is a counter growing since boot or a gauge describing the last interval
+type MetricKind string
+
+const (
+	//CumulativeKind metric value is a counter which only grows since boot
+	CumulativeKind MetricKind = "cumulative"
+
+	//GaugeKind metric value describes the interval since the previous collection
+	GaugeKind MetricKind = "gauge"
+
+	//jiffiesUnit unit of time reported by /proc/stat, USER_HZ (1/100ths of a second on most architectures)
+	jiffiesUnit = "jiffies"
+
+	//percentUnit unit of percentages
+	percentUnit = "percent"
+
+	//jiffiesPerSecondUnit unit of rates of time spent in CPU states
+	jiffiesPerSecondUnit = "jiffies/second"
+
+	//secondsUnit unit of wall-clock time
+	secondsUnit = "seconds"
+)
+
+//MetricDescriptor registry entry describing metric, it is used by GetMetricTypes, METRICS.md
+//and consumers outside of Snap
+type MetricDescriptor struct {
+	Name           string // last element of namespace, e.g. user_jiffies
+	State          string // CPU state or /proc/stat column, e.g. user
+	Representation string // e.g. jiffies
+	Derived        bool   // state is derived from other states (active, utilization), so its time overlaps with theirs
+	Unit           string
+	Kind           MetricKind
+	Description    string
+}
+
+//representation way in which time spent in CPU state is represented by metric
+type representation struct {
+	name        string // suffix of metric name, e.g. jiffies
+	unit        string
+	kind        MetricKind
+	description string // %s is replaced with description of CPU state
+	sampled     bool   // statistic of percentages sampled by background sampler, available only when it is enabled
+}
+
+//intervalMetricDescriptor registry entry of metric reporting wall-clock time which percentages, deltas and rates
+//are calculated over, it doesn't describe any CPU state, so its representation is equal to its name
+var intervalMetricDescriptor = MetricDescriptor{
+	Name:           intervalMetricName,
+	Representation: intervalMetricName,
+	Unit:           secondsUnit,
+	Kind:           GaugeKind,
+	Description:    "The wall-clock time between the previous and the current collection which percentages, deltas and rates are calculated over",
+}
+
+//rollingRepresentation returns representation of rolling average of percentages over window with given label, e.g. 5m
+func rollingRepresentation(label string) representation {
+	return representation{
+		name:        percentageRepresentationType + "_" + label,
+		unit:        percentUnit,
+		kind:        GaugeKind,
+		description: "The time-weighted average percent of time %s by CPU with given identifier over the last " + label,
+	}
+}
+
+//representations ways of representing CPU states, in the order of METRICS.md
+var representations = []representation{
+	{jiffiesRepresentationType, jiffiesUnit, CumulativeKind, "The amount of time %s by CPU with given identifier", false},
+	{percentageRepresentationType, percentUnit, GaugeKind, "The percent of time %s by CPU with given identifier", false},
+	{deltaRepresentationType, jiffiesUnit, GaugeKind, "The amount of time %s by CPU with given identifier since the previous collection", false},
+	{rateRepresentationType, jiffiesPerSecondUnit, GaugeKind, "The amount of time per second %s by CPU with given identifier since the previous collection", false},
+	{percentageAvgRepresentationType, percentUnit, GaugeKind, "The average percent of time %s by CPU with given identifier over sub-intervals sampled since the previous collection", true},
+	{percentageMaxRepresentationType, percentUnit, GaugeKind, "The maximal percent of time %s by CPU with given identifier over sub-intervals sampled since the previous collection", true},
+	{percentageMinRepresentationType, percentUnit, GaugeKind, "The minimal percent of time %s by CPU with given identifier over sub-intervals sampled since the previous collection", true},
+	{percentageP95RepresentationType, percentUnit, GaugeKind, "The 95th percentile of percent of time %s by CPU with given identifier over sub-intervals sampled since the previous collection", true},
+}
+
+//cpuState state of CPU which metrics are reported for
+type cpuState struct {
+	name        string
+	description string
+}
+
+//cpuStates states of CPU in the order of /proc/stat columns followed by snap specific ones
+var cpuStates = []cpuState{
+	{userProcStat, "spent in user mode"},
+	{niceProcStat, "spent in user mode with low priority"},
+	{systemProcStat, "spent in system mode"},
+	{idleProcStat, "spent in the idle task"},
+	{iowaitProcStat, "spent waiting for I/O to complete"},
+	{irqProcStat, "spent servicing interrupts"},
+	{softirqProcStat, "spent servicing softirqs"},
+	{stealProcStat, "stolen, which is the time spent in other operating systems when running in a virtualized environment,"},
+	{guestProcStat, "spent running a virtual CPU for guest operating systems under the control of the Linux kernel"},
+	{guestNiceProcStat, "spent running a niced guest (virtual CPU for guest operating systems under the control of the Linux kernel)"},
+	{activeProcStat, "spent in non idle state"},
+	{utilizationProcStat, "spent in non idle and non iowait states"},
+}
+
+//getMetricDescriptor returns registry entry of metric representing CPU state in given way,
+//columns unknown to plugin (e.g. column11) get a generic description
+func getMetricDescriptor(stateName string, repr representation) MetricDescriptor {
+	description := fmt.Sprintf("reported in %s of /proc/stat CPU line (not known to plugin)", stateName)
+	for _, state := range cpuStates {
+		if state.name == stateName {
+			description = state.description
+		}
+	}
+	return MetricDescriptor{
+		Name:           getNamespaceMetricPart(stateName, repr.name),
+		State:          stateName,
+		Representation: repr.name,
+		Derived:        stateName == activeProcStat || stateName == utilizationProcStat,
+		Unit:           repr.unit,
+		Kind:           repr.kind,
+		Description:    fmt.Sprintf(repr.description, description),
+	}
+}
+
+//splitMetricName splits name of metric (last element of namespace) into CPU state and its representation
+func splitMetricName(name string) (string, representation, bool) {
+	for _, repr := range representations {
+		if suffix := "_" + repr.name; strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix), repr, true
+		}
+	}
+	infix := "_" + percentageRepresentationType + "_"
+	if i := strings.LastIndex(name, infix); i > 0 && isRollingState(name[:i]) {
+		label := name[i+len(infix):]
+		if _, ok := parseWindowLabel(label); ok {
+			return name[:i], rollingRepresentation(label), true
+		}
+	}
+	return "", representation{}, false
+}
+
+//DescribeMetric returns registry entry of metric with given name (last element of namespace), e.g. user_jiffies,
+//metrics not describing any CPU state (interval_seconds) have empty state and representation equal to their name
+func DescribeMetric(name string) (MetricDescriptor, bool) {
+	if name == intervalMetricName {
+		return intervalMetricDescriptor, true
+	}
+	stateName, repr, ok := splitMetricName(name)
+	if !ok {
+		return MetricDescriptor{}, false
+	}
+	return getMetricDescriptor(stateName, repr), true
+}
+
+//getMetricsRegistry returns registry entries of metrics for given CPU states, grouped by representation,
+//statistics of sampled percentages are included when sampled is set and rolling averages for windows with given labels
+func getMetricsRegistry(stateNames []string, sampled bool, windows []string) []MetricDescriptor {
+	registry := []MetricDescriptor{}
+	for _, repr := range representations {
+		if repr.sampled && !sampled {
+			continue
+		}
+		for _, stateName := range stateNames {
+			registry = append(registry, getMetricDescriptor(stateName, repr))
+		}
+	}
+	for _, label := range windows {
+		for _, stateName := range stateNames {
+			if isRollingState(stateName) {
+				registry = append(registry, getMetricDescriptor(stateName, rollingRepresentation(label)))
+			}
+		}
+	}
+	return append(registry, intervalMetricDescriptor)
+}
+
+//rollingWindowPlaceholder label of rolling window in METRICS.md, e.g. 5m in active_percentage_5m
+const rollingWindowPlaceholder = "<window>"
+
+//WriteMetricsDoc writes METRICS.md documenting all metrics from registry
+func WriteMetricsDoc(w io.Writer) error {
+	stateNames := []string{}
+	for _, state := range cpuStates {
+		stateNames = append(stateNames, state.name)
+	}
+	unknownColumn := unknownColumnPrefix + "<N>"
+	stateNames = append(stateNames, unknownColumn)
+
+	lines := []string{
+		"<!-- Code generated by go generate ./cpu from the metric registry in cpu/metrics.go; DO NOT EDIT. -->",
+		"",
+		"# snap collector plugin - cpu",
+		"",
+		"## Collected Metrics",
+		"",
+		"Note that in the following table, the dynamic component of the namespace (*)",
+		"is either the \\<CPU ID/number\\> or 'all' when the metric is aggregated across all CPUs.",
+		"Metrics for columns not reported by kernel of the host (e.g. guest_nice on kernels older than 2.6.33) are not available.",
+		"Percentages are calculated over the interval since the previous collection done by the same task,",
+		"so they are not available in the first collection. The same applies to deltas and rates, which are never negative:",
+		"values of counters going back are not reported. Rates are calculated over the wall-clock time between collections",
+		"reported as interval_seconds, which is reported only for 'all' when metrics of all CPUs (*) are requested.",
+		"Statistics of percentages (avg, max, min and p95) are calculated over sub-intervals sampled by background sampler",
+		"since the previous collection, they are available only when the sampling_interval configuration item is set.",
+		"Rolling averages are calculated from counters stored at the beginning of each window given in the rolling_windows",
+		"configuration item (e.g. 1m,5m,15m), they are available once the task has collected for the whole window.",
+		"Instead of a CPU ID, percentages may be requested with 'min', 'max', 'mean', 'stddev' (population standard deviation),",
+		"'cv' (coefficient of variation) or 'max_cpu' (ID of CPU with the max value) as the dynamic component,",
+		"they are statistics of the percentage across CPUs and are not included in metrics of all CPUs (*).",
+		"",
+		"This plugin has the ability to gather the following metrics:",
+		"",
+		"Namespace | Unit | Kind | Description",
+		"----------|------|------|------------",
+	}
+	for _, descriptor := range getMetricsRegistry(stateNames, true, []string{rollingWindowPlaceholder}) {
+		line := fmt.Sprintf("/%s/%s/%s/*/%s | %s | %s | %s",
+			vendor, fs, pluginName, descriptor.Name, descriptor.Unit, descriptor.Kind, descriptor.Description)
+		line = strings.Replace(line, unknownColumn, unknownColumnPrefix+"\\<N\\>", -1)
+		lines = append(lines, strings.Replace(line, rollingWindowPlaceholder, "\\<window\\>", -1))
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
@@ -0,0 +1,136 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMetricsRegistry(t *testing.T) {
+	Convey("Given metric registry", t, func() {
+		Convey("metrics should be described with units and kinds", func() {
+			descriptor, ok := DescribeMetric("idle_percentage")
+			So(ok, ShouldBeTrue)
+			So(descriptor.Name, ShouldEqual, "idle_percentage")
+			So(descriptor.Unit, ShouldEqual, percentUnit)
+			So(descriptor.Kind, ShouldEqual, GaugeKind)
+			So(descriptor.Description, ShouldEqual, "The percent of time spent in the idle task by CPU with given identifier")
+
+			descriptor, ok = DescribeMetric("guest_nice_jiffies")
+			So(ok, ShouldBeTrue)
+			So(descriptor.Unit, ShouldEqual, jiffiesUnit)
+			So(descriptor.Kind, ShouldEqual, CumulativeKind)
+			So(descriptor.Description, ShouldStartWith, "The amount of time spent running a niced guest")
+
+			descriptor, ok = DescribeMetric("column11_jiffies")
+			So(ok, ShouldBeTrue)
+			So(descriptor.Description, ShouldContainSubstring, "column11")
+
+			descriptor, ok = DescribeMetric(intervalMetricName)
+			So(ok, ShouldBeTrue)
+			So(descriptor.State, ShouldBeEmpty)
+			So(descriptor.Representation, ShouldEqual, intervalMetricName)
+
+			_, ok = DescribeMetric("user")
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("metrics should be described for consumers outside of Snap", func() {
+			descriptor, ok := DescribeMetric("guest_nice_percentage")
+			So(ok, ShouldBeTrue)
+			So(descriptor.State, ShouldEqual, guestNiceProcStat)
+			So(descriptor.Representation, ShouldEqual, percentageRepresentationType)
+			So(descriptor.Unit, ShouldEqual, percentUnit)
+			So(descriptor.Kind, ShouldEqual, GaugeKind)
+
+			_, ok = DescribeMetric("user")
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("METRICS.md should be generated from registry", func() {
+			expected := &bytes.Buffer{}
+			So(WriteMetricsDoc(expected), ShouldBeNil)
+			actual, err := ioutil.ReadFile("../METRICS.md")
+			So(err, ShouldBeNil)
+			// run go generate ./cpu when this fails
+			So(string(actual), ShouldEqual, expected.String())
+
+			for _, state := range cpuStates {
+				So(expected.String(), ShouldContainSubstring, "/intel/procfs/cpu/*/"+state.name+"_jiffies | jiffies | cumulative |")
+				So(expected.String(), ShouldContainSubstring, "/intel/procfs/cpu/*/"+state.name+"_percentage | percent | gauge |")
+			}
+		})
+	})
+
+	Convey("Given cpu plugin", t, func() {
+		procPath, err := ioutil.TempDir("", "snap-plugin-collector-cpu")
+		So(err, ShouldBeNil)
+		Reset(func() { os.RemoveAll(procPath) })
+		writeProcStat(procPath, firstProcStatSample)
+		cfg := plugin.Config{"proc_path": procPath}
+		p := New()
+
+		Convey("metric types should be described by registry", func() {
+			mts, err := p.GetMetricTypes(cfg)
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 12*4+1)
+			for _, mt := range mts {
+				So(mt.Description, ShouldNotStartWith, "dynamic CPU metric")
+				switch name := mt.Namespace[4].Value; {
+				case strings.HasSuffix(name, "_jiffies"), strings.HasSuffix(name, "_delta"):
+					So(mt.Unit, ShouldEqual, jiffiesUnit)
+				case strings.HasSuffix(name, "_rate"):
+					So(mt.Unit, ShouldEqual, jiffiesPerSecondUnit)
+				case name == intervalMetricName:
+					So(mt.Unit, ShouldEqual, secondsUnit)
+				default:
+					So(mt.Unit, ShouldEqual, percentUnit)
+				}
+				descriptor, ok := DescribeMetric(mt.Namespace[4].Value)
+				So(ok, ShouldBeTrue)
+				So(mt.Description, ShouldEqual, descriptor.Description)
+				So(mt.Tags[kindTag], ShouldEqual, string(descriptor.Kind))
+			}
+		})
+
+		Convey("collected metrics should have units", func() {
+			mts, err := p.CollectMetrics([]plugin.Metric{
+				plugin.Metric{
+					Namespace: plugin.NewNamespace(vendor, fs, pluginName, "*", getNamespaceMetricPart(idleProcStat, jiffiesRepresentationType)),
+					Config:    cfg,
+				},
+			})
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 2)
+			for _, mt := range mts {
+				So(mt.Unit, ShouldEqual, jiffiesUnit)
+			}
+		})
+	})
+}
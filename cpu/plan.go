@@ -0,0 +1,102 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"fmt"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+//collectionPlan metrics requested by task from source resolved from their namespaces before anything is read,
+//it tells which parts of source have to be read and which values have to be calculated
+type collectionPlan struct {
+	metrics []plannedMetric
+	perCPU  bool // metrics of individual CPUs are requested, otherwise only the line of all CPUs is read
+	sampled bool // statistics of percentages sampled by background sampler are requested
+	rolling bool // rolling averages are requested, so that history of counters is kept
+	paired  bool // values calculated against the previous sample are requested
+}
+
+//plannedMetric requested metric together with way of calculating it
+type plannedMetric struct {
+	metricType plugin.Metric
+	ref        metricRef
+	cpuID      string        // "all", identifier of CPU or "*" for all CPUs and "all"
+	selection  *cpuSelection // CPUs reported for "*", nil when all of them are reported
+}
+
+//planCollection resolves metrics requested from source, metrics requested for all CPUs ("*")
+//which are not reported by source are skipped while the ones requested for given CPU are reported as error
+func (s *source) planCollection(metricTypes []plugin.Metric) (*collectionPlan, error) {
+	plan := &collectionPlan{metrics: make([]plannedMetric, 0, len(metricTypes))}
+	//selections are usually the same for all metrics of task, so that they are parsed once
+	selections := map[string]*cpuSelection{}
+	for _, metricType := range metricTypes {
+		ns := metricType.Namespace
+		if len(ns) != maxNamespaceSize {
+			return nil, fmt.Errorf("Incorrect namespace length (len = %d)", len(ns))
+		}
+		name, cpuID := ns[len(ns)-1].Value, ns[len(ns)-2].Value
+		ref, ok := s.metrics[name]
+		if !ok {
+			if cpuID == "*" {
+				continue
+			}
+			if _, repr, ok := splitMetricName(name); ok && repr.sampled && s.sampler == nil {
+				return nil, fmt.Errorf("Metric {%s} requires sampling_interval configuration item", name)
+			}
+			return nil, fmt.Errorf("Unknown metric {%s}", name)
+		}
+		if isDistributionStatistic(cpuID) && ref.repr != percentageRepresentationType {
+			return nil, fmt.Errorf("Statistic {%s} across CPUs is available for percentages only, not for {%s}", cpuID, name)
+		}
+		planned := plannedMetric{metricType: metricType, ref: ref, cpuID: cpuID}
+		if cpuID == "*" {
+			cfg := metricType.Config
+			key := fmt.Sprintf("%v;%v;%v", cfg["aggregates_only"], cfg["cpu_list"], cfg["top_cpus"])
+			selection, ok := selections[key]
+			if !ok {
+				var err error
+				if selection, err = getCPUSelection(cfg); err != nil {
+					return nil, err
+				}
+				selections[key] = selection
+			}
+			planned.selection = selection
+		}
+		plan.metrics = append(plan.metrics, planned)
+		if cpuID != allCPU {
+			plan.perCPU = true
+		}
+		if ref.statistic != "" {
+			plan.sampled = true
+		}
+		if ref.window > 0 {
+			plan.rolling = true
+		}
+		if ref.paired() {
+			plan.paired = true
+		}
+	}
+	return plan, nil
+}
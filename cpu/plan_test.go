@@ -0,0 +1,87 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCollectionPlan(t *testing.T) {
+	Convey("Given source read from in-memory fixture", t, func() {
+		fixture := MapFS{"proc/stat": firstProcStatSample}
+		p := NewWithFileSystem(fixture)
+		cfg := plugin.Config{"proc_path": "/proc"}
+		src, err := p.getSource(cfg)
+		So(err, ShouldBeNil)
+		request := func(cpuID string, name string) plugin.Metric {
+			return plugin.Metric{Namespace: plugin.NewNamespace(vendor, fs, pluginName, cpuID, name), Config: cfg}
+		}
+
+		Convey("only the line of all CPUs should be planned for aggregate metrics", func() {
+			plan, err := src.planCollection([]plugin.Metric{request(allCPU, "user_percentage"), request(allCPU, "active_jiffies")})
+			So(err, ShouldBeNil)
+			So(len(plan.metrics), ShouldEqual, 2)
+			So(plan.metrics[1].ref.column, ShouldEqual, activeColumn)
+			So(plan.perCPU, ShouldBeFalse)
+
+			plan, err = src.planCollection([]plugin.Metric{request(allCPU, "user_percentage"), request("0", "user_percentage")})
+			So(err, ShouldBeNil)
+			So(plan.perCPU, ShouldBeTrue)
+
+			plan, err = src.planCollection([]plugin.Metric{request("*", "user_percentage")})
+			So(err, ShouldBeNil)
+			So(plan.perCPU, ShouldBeTrue)
+		})
+
+		Convey("metrics not reported by source should be resolved before reading it", func() {
+			plan, err := src.planCollection([]plugin.Metric{request("*", "column11_jiffies")})
+			So(err, ShouldBeNil)
+			So(plan.metrics, ShouldBeEmpty)
+
+			_, err = src.planCollection([]plugin.Metric{request(allCPU, "column11_jiffies")})
+			So(err, ShouldNotBeNil)
+
+			delete(fixture, "proc/stat")
+			mts, err := p.CollectMetrics([]plugin.Metric{request("*", "column11_jiffies")})
+			So(err, ShouldBeNil)
+			So(mts, ShouldBeEmpty)
+		})
+
+		Convey("lines of individual CPUs should not be parsed when only aggregates are requested", func() {
+			aggregates := []plugin.Metric{request(allCPU, "user_jiffies"), request(allCPU, "user_percentage")}
+			mts, err := p.CollectMetrics(aggregates)
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 2)
+			fixture["proc/stat"] = "cpu  150 0 150 900 0 0 0 0 0 0\ncpu0 broken\n"
+			mts, err = p.CollectMetrics(aggregates)
+			So(err, ShouldBeNil)
+			So(mts[0].Data, ShouldEqual, uint64(150))
+			So(mts[1].Data, ShouldEqual, 25)
+
+			_, err = p.CollectMetrics([]plugin.Metric{request("*", "user_percentage")})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,232 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cpu/procstat"
+)
+
+const (
+	//minRollingWindow shortest window of rolling averages
+	minRollingWindow = time.Second
+
+	//rollingWindowSteps number of steps window of rolling averages is divided into, counters are stored
+	//at most once per step, so that window start is found with accuracy of window length divided by steps
+	rollingWindowSteps = 30
+)
+
+//rollingStates CPU states which rolling averages of percentages are reported for
+var rollingStates = []string{activeProcStat, utilizationProcStat, iowaitProcStat, stealProcStat}
+
+//rollingPoint counters of CPUs stored in history of rolling averages together with time they were read at
+type rollingPoint struct {
+	ts   time.Time
+	all  procstat.CPUTimes
+	cpus []procstat.CPUStat
+}
+
+//rollingWindow history of counters kept for rolling averages over window of given length,
+//history is a ring of fixed size whose memory is reused, it covers at least the length of window
+type rollingWindow struct {
+	length time.Duration
+	points []rollingPoint
+	next   int // position in ring written by the next point
+	count  int // number of valid points in ring
+}
+
+//rollingHistory histories of counters kept for rolling averages over windows of given lengths
+type rollingHistory struct {
+	windows []*rollingWindow
+}
+
+//newRollingHistory creates empty histories for windows of given lengths
+func newRollingHistory(lengths []time.Duration) *rollingHistory {
+	h := &rollingHistory{}
+	for _, length := range lengths {
+		h.windows = append(h.windows, &rollingWindow{
+			length: length,
+			points: make([]rollingPoint, rollingWindowSteps+2),
+		})
+	}
+	return h
+}
+
+//add stores counters of sample read at given time in histories of windows whose step passed since the newest point
+func (h *rollingHistory) add(sample *procstat.Sample, ts time.Time) {
+	for _, w := range h.windows {
+		if w.count > 0 && ts.Sub(w.newest().ts) < w.length/rollingWindowSteps {
+			continue
+		}
+		point := &w.points[w.next]
+		point.ts = ts
+		copyTimes(&point.all, sample.All)
+		if cap(point.cpus) < len(sample.CPUs) {
+			point.cpus = make([]procstat.CPUStat, len(sample.CPUs))
+		}
+		point.cpus = point.cpus[:len(sample.CPUs)]
+		for i, cpu := range sample.CPUs {
+			point.cpus[i].ID = cpu.ID
+			copyTimes(&point.cpus[i].Times, cpu.Times)
+		}
+		w.next = (w.next + 1) % len(w.points)
+		if w.count < len(w.points) {
+			w.count++
+		}
+	}
+}
+
+//window returns history of window of given length or nil when it is not kept
+func (h *rollingHistory) window(length time.Duration) *rollingWindow {
+	for _, w := range h.windows {
+		if w.length == length {
+			return w
+		}
+	}
+	return nil
+}
+
+//newest returns the most recently stored point, w.count must be positive
+func (w *rollingWindow) newest() *rollingPoint {
+	return &w.points[(w.next-1+len(w.points))%len(w.points)]
+}
+
+//start returns point whose time is the closest one to beginning of window ending at given time,
+//it is nil until history covers the whole window (up to a step of window) so that averages over shorter
+//time are never reported as averages over window, and when there is no point before end of window
+func (w *rollingWindow) start(end time.Time) *rollingPoint {
+	begin := end.Add(-w.length)
+	if w.count == 0 || w.points[(w.next-w.count+len(w.points))%len(w.points)].ts.After(begin.Add(w.length/rollingWindowSteps)) {
+		return nil
+	}
+	var best *rollingPoint
+	var bestDistance time.Duration
+	for k := 0; k < w.count; k++ {
+		point := &w.points[(w.next-w.count+k+len(w.points))%len(w.points)]
+		if !point.ts.Before(end) {
+			continue
+		}
+		distance := point.ts.Sub(begin)
+		if distance < 0 {
+			distance = -distance
+		}
+		if best == nil || distance < bestDistance {
+			best, bestDistance = point, distance
+		}
+	}
+	return best
+}
+
+//copyTimes copies CPU times reusing memory of extra columns of destination,
+//extra columns of source belong to parser and they are overwritten by the next sample
+func copyTimes(dst *procstat.CPUTimes, src procstat.CPUTimes) {
+	extra := append(dst.Extra[:0], src.Extra...)
+	*dst = src
+	dst.Extra = extra
+	if len(extra) == 0 {
+		dst.Extra = nil
+	}
+}
+
+//rollingValue returns time-weighted average of percentage of metric for CPU at given position of current sample
+//(all CPUs when i is negative) over rolling window ending at given time, it is calculated from counters
+//stored at beginning of window and current ones; value is nil until history covers the whole window
+func (st *sampleState) rollingValue(ref metricRef, i int, id int, end time.Time) interface{} {
+	if st.rolling == nil {
+		return nil
+	}
+	w := st.rolling.window(ref.window)
+	if w == nil {
+		return nil
+	}
+	point := w.start(end)
+	if point == nil {
+		return nil
+	}
+	if i < 0 {
+		return ref.value(st.curr.All, &point.all, 0)
+	}
+	prev := findCPU(point.cpus, i, id)
+	if prev == nil {
+		return nil
+	}
+	return ref.value(st.curr.CPUs[i].Times, prev, 0)
+}
+
+//parseRollingWindows parses comma separated lengths of rolling windows given in rolling_windows config item,
+//e.g. "1m,5m,15m"; lengths are whole seconds and they have distinct labels
+func parseRollingWindows(value string) ([]time.Duration, error) {
+	lengths := []time.Duration{}
+	seen := map[time.Duration]bool{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		length, err := time.ParseDuration(item)
+		if err != nil {
+			return nil, fmt.Errorf("Incorrect rolling_windows item {%s}: %v", item, err)
+		}
+		if length < minRollingWindow || length%time.Second != 0 {
+			return nil, fmt.Errorf("Incorrect rolling_windows item {%s}, expected whole seconds, at least %v", item, minRollingWindow)
+		}
+		if seen[length] {
+			return nil, fmt.Errorf("Duplicated rolling_windows item {%s}", item)
+		}
+		seen[length] = true
+		lengths = append(lengths, length)
+	}
+	return lengths, nil
+}
+
+//windowLabel returns label of rolling window used in metric names, e.g. 5m or 90s
+func windowLabel(length time.Duration) string {
+	switch {
+	case length%time.Hour == 0:
+		return fmt.Sprintf("%dh", length/time.Hour)
+	case length%time.Minute == 0:
+		return fmt.Sprintf("%dm", length/time.Minute)
+	}
+	return fmt.Sprintf("%ds", length/time.Second)
+}
+
+//parseWindowLabel returns length of rolling window with given label
+func parseWindowLabel(label string) (time.Duration, bool) {
+	length, err := time.ParseDuration(label)
+	if err != nil || length < minRollingWindow || windowLabel(length) != label {
+		return 0, false
+	}
+	return length, true
+}
+
+//isRollingState tells whether rolling averages are reported for CPU state
+func isRollingState(stateName string) bool {
+	for _, name := range rollingStates {
+		if name == stateName {
+			return true
+		}
+	}
+	return false
+}
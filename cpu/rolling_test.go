@@ -0,0 +1,175 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cpu/procstat"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRollingAverages(t *testing.T) {
+	Convey("Given rolling_windows config item", t, func() {
+		Convey("windows should be parsed and validated", func() {
+			windows, err := parseRollingWindows("1m, 5m,15m")
+			So(err, ShouldBeNil)
+			So(windows, ShouldResemble, []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute})
+			windows, err = parseRollingWindows("")
+			So(err, ShouldBeNil)
+			So(windows, ShouldBeEmpty)
+			_, err = parseRollingWindows("500ms")
+			So(err, ShouldNotBeNil)
+			_, err = parseRollingWindows("1m,60s")
+			So(err, ShouldNotBeNil)
+			_, err = parseRollingWindows("often")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("windows should be labeled in metric names", func() {
+			So(windowLabel(90*time.Second), ShouldEqual, "90s")
+			So(windowLabel(5*time.Minute), ShouldEqual, "5m")
+			So(windowLabel(time.Hour), ShouldEqual, "1h")
+			length, ok := parseWindowLabel("15m")
+			So(ok, ShouldBeTrue)
+			So(length, ShouldEqual, 15*time.Minute)
+			_, ok = parseWindowLabel("60s")
+			So(ok, ShouldBeFalse)
+
+			descriptor, ok := DescribeMetric("utilization_percentage_5m")
+			So(ok, ShouldBeTrue)
+			So(descriptor.State, ShouldEqual, utilizationProcStat)
+			So(descriptor.Representation, ShouldEqual, "percentage_5m")
+			So(descriptor.Unit, ShouldEqual, percentUnit)
+			_, ok = DescribeMetric("user_percentage_5m")
+			So(ok, ShouldBeFalse)
+			_, ok = DescribeMetric("active_percentage_5x")
+			So(ok, ShouldBeFalse)
+		})
+	})
+
+	Convey("Given history of counters", t, func() {
+		t0 := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+		history := newRollingHistory([]time.Duration{time.Minute})
+		sample := func(user, idle uint64) *procstat.Sample {
+			times := procstat.CPUTimes{User: user, Idle: idle}
+			return &procstat.Sample{Columns: 10, All: times, CPUs: []procstat.CPUStat{{ID: 0, Times: times}}}
+		}
+
+		Convey("counters should be stored at most once per step of window", func() {
+			for i := 0; i < 10; i++ {
+				history.add(sample(uint64(i), 0), t0.Add(time.Duration(i)*time.Second))
+			}
+			So(history.window(time.Minute).count, ShouldEqual, 5)
+		})
+
+		Convey("memory of history should be bounded", func() {
+			for i := 0; i < 1000; i++ {
+				history.add(sample(uint64(i), 0), t0.Add(time.Duration(i)*time.Second))
+			}
+			w := history.window(time.Minute)
+			So(w.count, ShouldEqual, rollingWindowSteps+2)
+			So(w.start(t0.Add(1000*time.Second)).ts, ShouldResemble, t0.Add(940*time.Second))
+		})
+
+		Convey("extra columns should not be shared with parser", func() {
+			s := sample(1, 1)
+			s.All.Extra = []uint64{7}
+			history.add(s, t0)
+			s.All.Extra[0] = 8
+			So(history.window(time.Minute).newest().all.Extra, ShouldResemble, []uint64{7})
+		})
+
+		Convey("average should be weighted by time rather than averaged over collections", func() {
+			history.add(sample(0, 0), t0)
+			history.add(sample(50, 0), t0.Add(50*time.Second))
+			st := newSampleState()
+			st.rolling = history
+			st.curr = *sample(50, 10)
+			ref := metricRef{name: "active_percentage_1m", column: activeColumn, repr: percentageRepresentationType, window: time.Minute}
+			end := t0.Add(time.Minute)
+			So(st.rollingValue(ref, -1, -1, end), ShouldAlmostEqual, 100*50.0/60)
+			So(st.rollingValue(ref, 0, 0, end), ShouldAlmostEqual, 100*50.0/60)
+			So(st.rollingValue(ref, 0, 1, end), ShouldBeNil)
+
+			//history covering only part of window is not used
+			So(st.rollingValue(ref, -1, -1, t0.Add(80*time.Second)), ShouldNotBeNil)
+			So(st.rollingValue(ref, -1, -1, t0.Add(50*time.Second)), ShouldBeNil)
+
+			//window shorter than history starts at the closest stored counters
+			ref.window = 5 * time.Second
+			st.rolling = newRollingHistory([]time.Duration{5 * time.Second})
+			st.rolling.add(sample(0, 0), t0)
+			st.rolling.add(sample(50, 0), t0.Add(50*time.Second))
+			So(st.rollingValue(ref, -1, -1, end), ShouldEqual, 0)
+		})
+	})
+
+	Convey("Given plugin with rolling windows", t, func() {
+		t0 := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+		fixture := &clockFS{MapFS: MapFS{"proc/stat": firstProcStatSample}, now: t0}
+		p := NewWithFileSystem(fixture)
+		cfg := plugin.Config{"proc_path": "/proc", "rolling_windows": "1m,5m"}
+		request := func(cpuID string, name string) plugin.Metric {
+			return plugin.Metric{Namespace: plugin.NewNamespace(vendor, fs, pluginName, cpuID, name), Config: cfg}
+		}
+
+		Convey("rolling averages should be available", func() {
+			mts, err := p.GetMetricTypes(cfg)
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 12*4+1+4*2)
+
+			requests := []plugin.Metric{request("*", "active_percentage"), request("*", "active_percentage_1m"), request(allCPU, "iowait_percentage_5m")}
+			mts, err = p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 1)
+			So(mts[0].Data, ShouldBeNil)
+
+			fixture.MapFS["proc/stat"] = secondProcStatSample
+			fixture.now = t0.Add(time.Minute)
+			mts, err = p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 5)
+			So(mts[0].Data, ShouldEqual, 100*100.0/200)
+			So(mts[2].Data, ShouldEqual, mts[0].Data)
+			So(mts[3].Data, ShouldEqual, mts[1].Data)
+			//history does not cover 5 minutes yet
+			So(mts[4].Data, ShouldBeNil)
+
+			fixture.MapFS["proc/stat"] = "cpu  200 0 200 1000 0 0 0 0 0 0\ncpu0 200 0 200 1000 0 0 0 0 0 0"
+			fixture.now = t0.Add(5 * time.Minute)
+			mts, err = p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 5)
+			So(mts[4].Data, ShouldEqual, 0)
+		})
+
+		Convey("rolling averages should not be available for other windows", func() {
+			_, err := p.CollectMetrics([]plugin.Metric{request(allCPU, "active_percentage_15m")})
+			So(err, ShouldNotBeNil)
+			_, err = p.GetMetricTypes(plugin.Config{"proc_path": "/proc", "rolling_windows": "1s,2"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,176 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cpu/procstat"
+)
+
+const (
+	//minSamplingInterval shortest sub-interval at which background sampler may read /proc/stat
+	minSamplingInterval = 100 * time.Millisecond
+
+	//defaultSamplingBuffer number of samples kept by background sampler unless sampling_buffer is set
+	defaultSamplingBuffer = 256
+
+	//maxSamplingBuffer max number of samples kept by background sampler, it bounds memory used by sampler
+	maxSamplingBuffer = 3600
+
+	//p95Rank rank of percentile reported by metrics in percentageP95RepresentationType
+	p95Rank = 0.95
+)
+
+//sampledSample sample of /proc/stat taken by background sampler together with its sequence number
+type sampledSample struct {
+	seq    uint64
+	sample procstat.Sample
+}
+
+//sampler reads /proc/stat of source in background at sub-interval between collections,
+//samples are kept in a ring of fixed size whose memory is reused, so that memory used by sampler is bounded;
+//when collections are further apart than the ring covers, statistics are calculated over the retained samples
+type sampler struct {
+	filesystem FileSystem
+	path       string
+	columns    int
+	interval   time.Duration
+	mutex      sync.Mutex
+	parser     procstat.Parser
+	ring       []sampledSample
+	next       int    // position in ring written by the next sample
+	count      int    // number of valid samples in ring
+	seq        uint64 // sequence number of the last sample taken, starting from 1
+	stop       chan struct{}
+	done       chan struct{}
+	stopOnce   sync.Once
+}
+
+//newSampler creates sampler reading /proc/stat with given number of columns every interval and keeping given number of samples, sampling starts immediately
+func newSampler(filesystem FileSystem, path string, columns int, interval time.Duration, size int) *sampler {
+	sm := &sampler{
+		filesystem: filesystem,
+		path:       path,
+		columns:    columns,
+		interval:   interval,
+		ring:       make([]sampledSample, size),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go sm.run()
+	return sm
+}
+
+//run takes samples every interval until sampler is stopped, failures are reported once until sampling recovers
+func (sm *sampler) run() {
+	defer close(sm.done)
+	ticker := time.NewTicker(sm.interval)
+	defer ticker.Stop()
+	failing := false
+	for {
+		select {
+		case <-sm.stop:
+			return
+		case <-ticker.C:
+			sm.mutex.Lock()
+			err := sm.take()
+			sm.mutex.Unlock()
+			if err != nil && !failing {
+				fmt.Fprintf(os.Stderr, "Background sampling of %s failed: %v\n", sm.path, err)
+			}
+			failing = err != nil
+		}
+	}
+}
+
+//close stops sampling and waits until sampler goroutine exits, it may be called several times
+func (sm *sampler) close() {
+	sm.stopOnce.Do(func() { close(sm.stop) })
+	<-sm.done
+}
+
+//take reads new sample into ring overwriting the oldest one when ring is full, sm.mutex must be held
+func (sm *sampler) take() error {
+	entry := &sm.ring[sm.next]
+	if err := readSample(sm.filesystem, sm.path, &sm.parser, &entry.sample, sm.columns, true); err != nil {
+		if sm.count == len(sm.ring) {
+			//the oldest sample was partially overwritten
+			sm.count--
+		}
+		return err
+	}
+	sm.seq++
+	entry.seq = sm.seq
+	sm.next = (sm.next + 1) % len(sm.ring)
+	if sm.count < len(sm.ring) {
+		sm.count++
+	}
+	return nil
+}
+
+//window appends samples with sequence number not lower than since to given slice from the oldest one,
+//sm.mutex must be held as long as returned samples are used
+func (sm *sampler) window(since uint64, window []*procstat.Sample) []*procstat.Sample {
+	for k := 0; k < sm.count; k++ {
+		entry := &sm.ring[(sm.next-sm.count+k+len(sm.ring))%len(sm.ring)]
+		if entry.seq >= since {
+			window = append(window, &entry.sample)
+		}
+	}
+	return window
+}
+
+//parseSamplingInterval parses sub-interval of background sampler given in sampling_interval config item,
+//empty or zero one disables sampler
+func parseSamplingInterval(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("Incorrect sampling_interval {%s}: %v", value, err)
+	}
+	if interval != 0 && interval < minSamplingInterval {
+		return 0, fmt.Errorf("Incorrect sampling_interval {%s}, expected at least %v", value, minSamplingInterval)
+	}
+	return interval, nil
+}
+
+//percentStatistic returns order statistic (max, min or p95) in given representation of sampled percentages,
+//order of values is changed; average is weighted by jiffies, so it is calculated by sampledValue
+func percentStatistic(statistic string, values []float64) float64 {
+	sort.Float64s(values)
+	switch statistic {
+	case percentageMaxRepresentationType:
+		return values[len(values)-1]
+	case percentageMinRepresentationType:
+		return values[0]
+	}
+	//p95, nearest rank
+	return values[int(math.Ceil(p95Rank*float64(len(values))))-1]
+}
@@ -0,0 +1,218 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//sampledProcStat returns /proc/stat with all CPUs and cpu0 reporting given user and idle times
+func sampledProcStat(user, idle int) string {
+	line := fmt.Sprintf("%d 0 0 %d 0 0 0 0 0 0\n", user, idle)
+	return "cpu  " + line + "cpu0 " + line + "intr 0\n"
+}
+
+func TestSampler(t *testing.T) {
+	Convey("Given sampling config items", t, func() {
+		Convey("sampling interval should be validated", func() {
+			interval, err := parseSamplingInterval("")
+			So(err, ShouldBeNil)
+			So(interval, ShouldEqual, 0)
+			interval, err = parseSamplingInterval("0")
+			So(err, ShouldBeNil)
+			So(interval, ShouldEqual, 0)
+			interval, err = parseSamplingInterval("2s")
+			So(err, ShouldBeNil)
+			So(interval, ShouldEqual, 2*time.Second)
+			_, err = parseSamplingInterval("10ms")
+			So(err, ShouldNotBeNil)
+			_, err = parseSamplingInterval("often")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("incorrect config should be reported", func() {
+			_, err := getSourceConfig(plugin.Config{"sampling_interval": "10ms"})
+			So(err, ShouldNotBeNil)
+			_, err = getSourceConfig(plugin.Config{"sampling_interval": "1s", "sampling_buffer": int64(1)})
+			So(err, ShouldNotBeNil)
+			srcCfg, err := getSourceConfig(plugin.Config{"sampling_interval": "1s"})
+			So(err, ShouldBeNil)
+			So(srcCfg.samplingBuffer, ShouldEqual, defaultSamplingBuffer)
+			So(srcCfg.key(), ShouldNotEqual, sourceKey(plugin.Config{}))
+		})
+	})
+
+	Convey("Given sampled percentages", t, func() {
+		values := []float64{}
+		for i := 20; i > 0; i-- {
+			values = append(values, float64(i))
+		}
+
+		Convey("statistics should be calculated", func() {
+			So(percentStatistic(percentageMaxRepresentationType, values), ShouldEqual, 20)
+			So(percentStatistic(percentageMinRepresentationType, values), ShouldEqual, 1)
+			So(percentStatistic(percentageP95RepresentationType, values), ShouldEqual, 19)
+			So(percentStatistic(percentageP95RepresentationType, []float64{42}), ShouldEqual, 42)
+		})
+	})
+
+	Convey("Given plugin with background sampler", t, func() {
+		fixture := MapFS{"proc/stat": sampledProcStat(0, 0)}
+		p := NewWithFileSystem(fixture)
+		//samples are taken by test, the interval is long enough for sampler not to take any
+		cfg := plugin.Config{"proc_path": "/proc", "sampling_interval": "1h", "sampling_buffer": int64(4)}
+		src, err := p.getSource(cfg)
+		So(err, ShouldBeNil)
+		So(src.sampler, ShouldNotBeNil)
+		Reset(p.Close)
+		request := func(cpuID string, name string) plugin.Metric {
+			return plugin.Metric{Namespace: plugin.NewNamespace(vendor, fs, pluginName, cpuID, name), Config: cfg}
+		}
+		take := func(user, idle int) {
+			fixture["proc/stat"] = sampledProcStat(user, idle)
+			src.sampler.mutex.Lock()
+			defer src.sampler.mutex.Unlock()
+			So(src.sampler.take(), ShouldBeNil)
+		}
+
+		Convey("statistics of percentages should be available", func() {
+			mts, err := p.GetMetricTypes(cfg)
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 12*8+1)
+		})
+
+		Convey("statistics should be calculated over sub-intervals since the previous collection", func() {
+			requests := []plugin.Metric{}
+			for _, name := range []string{"user_percentage", "user_percentage_avg", "user_percentage_max", "user_percentage_min", "user_percentage_p95"} {
+				requests = append(requests, request("*", name))
+			}
+			mts, err := p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			So(mts, ShouldBeEmpty)
+
+			take(10, 90)
+			take(60, 140)
+			fixture["proc/stat"] = sampledProcStat(80, 220)
+			mts, err = p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 10)
+			values := map[string]interface{}{}
+			for _, mt := range mts {
+				values[mt.Namespace[3].Value+"/"+mt.Namespace[4].Value] = mt.Data
+			}
+			So(values["0/user_percentage"], ShouldEqual, 80.0/3)
+			So(values["0/user_percentage_avg"], ShouldAlmostEqual, 80.0/3)
+			So(values["all/user_percentage_max"], ShouldEqual, 50)
+			So(values["0/user_percentage_min"], ShouldEqual, 10)
+			So(values["0/user_percentage_p95"], ShouldEqual, 50)
+
+			//the next window starts where the previous one ended
+			fixture["proc/stat"] = sampledProcStat(180, 220)
+			mts, err = p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			So(mts[len(mts)-1].Data, ShouldEqual, 100)
+		})
+
+		Convey("average should be weighted by jiffies of uneven sub-intervals", func() {
+			requests := []plugin.Metric{request("0", "user_percentage"), request("0", "user_percentage_avg")}
+			_, err := p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+
+			//10% of 100 jiffies followed by 5% of 400 jiffies
+			take(10, 90)
+			fixture["proc/stat"] = sampledProcStat(30, 470)
+			mts, err := p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 2)
+			So(mts[0].Data, ShouldEqual, 6)
+			So(mts[1].Data, ShouldEqual, 6)
+		})
+
+		Convey("memory of sampler should be bounded", func() {
+			for i := 1; i <= 5; i++ {
+				take(i, i)
+			}
+			So(src.sampler.count, ShouldEqual, 4)
+			So(len(src.sampler.window(1, nil)), ShouldEqual, 4)
+			So(len(src.sampler.window(5, nil)), ShouldEqual, 1)
+
+			//failed sample drops the oldest one it overwrote
+			fixture["proc/stat"] = "broken"
+			So(src.sampler.take(), ShouldNotBeNil)
+			So(src.sampler.count, ShouldEqual, 3)
+			window := src.sampler.window(1, nil)
+			So(len(window), ShouldEqual, 3)
+			So(window[0].All.User, ShouldEqual, uint64(3))
+		})
+	})
+
+	Convey("Given plugin without background sampler", t, func() {
+		p := NewWithFileSystem(MapFS{"proc/stat": sampledProcStat(0, 0)})
+		cfg := plugin.Config{"proc_path": "/proc"}
+
+		Convey("statistics of percentages should not be available", func() {
+			mts, err := p.GetMetricTypes(cfg)
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 12*4+1)
+
+			_, err = p.CollectMetrics([]plugin.Metric{
+				plugin.Metric{Namespace: plugin.NewNamespace(vendor, fs, pluginName, "0", "user_percentage_max"), Config: cfg},
+			})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "sampling_interval")
+		})
+	})
+
+	Convey("Given running background sampler", t, func() {
+		p := NewWithFileSystem(MapFS{"proc/stat": sampledProcStat(0, 0)})
+		src, err := p.getSource(plugin.Config{"proc_path": "/proc", "sampling_interval": "100ms"})
+		So(err, ShouldBeNil)
+		sm := src.sampler
+
+		Convey("it should sample in background and stop when plugin is closed", func() {
+			sampled := func() bool {
+				sm.mutex.Lock()
+				defer sm.mutex.Unlock()
+				return sm.seq > 0
+			}
+			for deadline := time.Now().Add(5 * time.Second); !sampled() && time.Now().Before(deadline); {
+				time.Sleep(10 * time.Millisecond)
+			}
+			So(sampled(), ShouldBeTrue)
+
+			p.Close()
+			So(p.sources, ShouldBeEmpty)
+			select {
+			case <-sm.done:
+			default:
+				So("sampler still running", ShouldBeEmpty)
+			}
+			//closing twice is harmless
+			sm.close()
+		})
+	})
+}
@@ -0,0 +1,152 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+//selectionConfigKeys config items which limit CPUs whose metrics are reported for all CPUs ("*"),
+//they don't tell tasks apart, so that baselines are kept when they are changed
+var selectionConfigKeys = map[string]bool{"cpu_list": true, "aggregates_only": true, "top_cpus": true}
+
+//cpuRange inclusive range of CPU IDs
+type cpuRange struct {
+	first int
+	last  int
+}
+
+//cpuSelection CPUs whose metrics are reported when metrics of all CPUs ("*") are requested, aggregates
+//are always reported; baselines of all CPUs are tracked regardless of selection
+type cpuSelection struct {
+	aggregatesOnly bool       // metrics of individual CPUs are not reported
+	ranges         []cpuRange // CPUs given by cpu_list, all CPUs when empty
+	top            int        // number of the busiest CPUs among listed ones, all listed CPUs when zero
+}
+
+//getCPUSelection returns selection of CPUs given in config, nil when metrics of all CPUs are reported
+func getCPUSelection(cfg plugin.Config) (*cpuSelection, error) {
+	selection := &cpuSelection{}
+	if aggregatesOnly, err := cfg.GetBool("aggregates_only"); err == nil {
+		selection.aggregatesOnly = aggregatesOnly
+	}
+	if list, err := cfg.GetString("cpu_list"); err == nil {
+		if selection.ranges, err = parseCPUList(list); err != nil {
+			return nil, err
+		}
+	}
+	if top, err := cfg.GetInt("top_cpus"); err == nil {
+		if top < 0 {
+			return nil, fmt.Errorf("Incorrect top_cpus {%d}, expected positive number or zero", top)
+		}
+		selection.top = int(top)
+	}
+	if !selection.aggregatesOnly && len(selection.ranges) == 0 && selection.top == 0 {
+		return nil, nil
+	}
+	return selection, nil
+}
+
+//parseCPUList parses list of CPU IDs and their ranges separated by commas, e.g. "0-3,8,16-31"
+func parseCPUList(list string) ([]cpuRange, error) {
+	var ranges []cpuRange
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		bounds := strings.SplitN(item, "-", 2)
+		first, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		last := first
+		if err == nil && len(bounds) == 2 {
+			last, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+		}
+		if err != nil || first < 0 || last < first {
+			return nil, fmt.Errorf("Incorrect cpu_list {%s}, expected CPU IDs and their ranges separated by commas, e.g. 0-3,8,16-31", list)
+		}
+		ranges = append(ranges, cpuRange{first: first, last: last})
+	}
+	return ranges, nil
+}
+
+//listed tells whether CPU with given ID is given by cpu_list
+func (c *cpuSelection) listed(id int) bool {
+	if len(c.ranges) == 0 {
+		return true
+	}
+	for _, r := range c.ranges {
+		if id >= r.first && id <= r.last {
+			return true
+		}
+	}
+	return false
+}
+
+//selectCPUs returns positions of selected CPUs in current sample in ascending order, the busiest CPUs
+//are the ones with the highest utilization since the previous sample, CPUs whose utilization is not known
+//are considered idle
+func (st *sampleState) selectCPUs(selection *cpuSelection) []int {
+	positions := []int{}
+	if selection.aggregatesOnly {
+		return positions
+	}
+	for i, cpu := range st.curr.CPUs {
+		if selection.listed(cpu.ID) {
+			positions = append(positions, i)
+		}
+	}
+	if selection.top == 0 || selection.top >= len(positions) {
+		return positions
+	}
+	busiest := byUtilization{positions: positions, utilization: make(map[int]float64, len(positions))}
+	for _, i := range positions {
+		if prev := st.prevCPU(i, st.curr.CPUs[i].ID); prev != nil {
+			delta := st.curr.CPUs[i].Times.Delta(*prev)
+			if percent, ok := delta.Percent(delta.Utilization()); ok {
+				busiest.utilization[i] = percent
+			}
+		}
+	}
+	sort.Stable(busiest)
+	positions = positions[:selection.top]
+	sort.Ints(positions)
+	return positions
+}
+
+//byUtilization sorts positions of CPUs by their utilization in descending order
+type byUtilization struct {
+	positions   []int
+	utilization map[int]float64 // utilization by position of CPU
+}
+
+func (b byUtilization) Len() int { return len(b.positions) }
+func (b byUtilization) Swap(i, j int) {
+	b.positions[i], b.positions[j] = b.positions[j], b.positions[i]
+}
+func (b byUtilization) Less(i, j int) bool {
+	return b.utilization[b.positions[i]] > b.utilization[b.positions[j]]
+}
@@ -0,0 +1,173 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseCPUList(t *testing.T) {
+	Convey("Given CPU list expressions", t, func() {
+		Convey("CPU IDs and their ranges should be parsed", func() {
+			ranges, err := parseCPUList("0-3, 8,16-31")
+			So(err, ShouldBeNil)
+			So(ranges, ShouldResemble, []cpuRange{{0, 3}, {8, 8}, {16, 31}})
+		})
+
+		Convey("incorrect expressions should be reported", func() {
+			for _, list := range []string{"a", "3-1", "-1", "1-", "1-2-3"} {
+				_, err := parseCPUList(list)
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
+
+func TestCPUSelection(t *testing.T) {
+	Convey("Given CPUs with utilization 0, 50, 100 and 50 percent", t, func() {
+		fixture := MapFS{"proc/stat": firstSpreadProcStatSample}
+		p := NewWithFileSystem(fixture)
+		cfg := plugin.Config{"proc_path": "/proc"}
+		request := func(selection plugin.Config) []plugin.Metric {
+			taskCfg := plugin.Config{}
+			for key, value := range cfg {
+				taskCfg[key] = value
+			}
+			for key, value := range selection {
+				taskCfg[key] = value
+			}
+			return []plugin.Metric{
+				plugin.Metric{Namespace: plugin.NewNamespace(vendor, fs, pluginName, "*", "user_percentage"), Config: taskCfg},
+			}
+		}
+		collect := func(selection plugin.Config) ([]string, []interface{}, error) {
+			mts, err := p.CollectMetrics(request(selection))
+			cpuIDs := []string{}
+			values := []interface{}{}
+			for _, mt := range mts {
+				cpuIDs = append(cpuIDs, mt.Namespace[len(mt.Namespace)-2].Value)
+				values = append(values, mt.Data)
+			}
+			return cpuIDs, values, err
+		}
+		//collectSecond collects the first sample and returns metrics collected from the second one
+		collectSecond := func(selection plugin.Config) ([]string, []interface{}, error) {
+			fixture["proc/stat"] = firstSpreadProcStatSample
+			if _, _, err := collect(selection); err != nil {
+				return nil, nil, err
+			}
+			fixture["proc/stat"] = secondSpreadProcStatSample
+			return collect(selection)
+		}
+
+		Convey("all CPUs should be reported without selection", func() {
+			cpuIDs, values, err := collectSecond(nil)
+			So(err, ShouldBeNil)
+			So(cpuIDs, ShouldResemble, []string{allCPU, "0", "1", "2", "3"})
+			So(values, ShouldResemble, []interface{}{50.0, 0.0, 50.0, 100.0, 50.0})
+		})
+
+		Convey("only aggregates should be reported in aggregates only mode", func() {
+			cpuIDs, values, err := collectSecond(plugin.Config{"aggregates_only": true})
+			So(err, ShouldBeNil)
+			So(cpuIDs, ShouldResemble, []string{allCPU})
+			So(values[0], ShouldEqual, 50)
+		})
+
+		Convey("only listed CPUs should be reported", func() {
+			cpuIDs, _, err := collectSecond(plugin.Config{"cpu_list": "1-2,7"})
+			So(err, ShouldBeNil)
+			So(cpuIDs, ShouldResemble, []string{allCPU, "1", "2"})
+		})
+
+		Convey("only the busiest CPUs should be reported", func() {
+			cpuIDs, _, err := collectSecond(plugin.Config{"top_cpus": int64(2)})
+			So(err, ShouldBeNil)
+			So(cpuIDs, ShouldResemble, []string{allCPU, "1", "2"})
+		})
+
+		Convey("the busiest CPUs should be chosen among listed ones", func() {
+			cpuIDs, _, err := collectSecond(plugin.Config{"top_cpus": int64(1), "cpu_list": "0,3"})
+			So(err, ShouldBeNil)
+			So(cpuIDs, ShouldResemble, []string{allCPU, "3"})
+		})
+
+		Convey("baselines of CPUs which were not reported should be tracked", func() {
+			selection := plugin.Config{"top_cpus": int64(1)}
+			cpuIDs, _, err := collectSecond(selection)
+			So(err, ShouldBeNil)
+			So(cpuIDs, ShouldResemble, []string{allCPU, "2"})
+			fixture["proc/stat"] = thirdSpreadProcStatSample
+			cpuIDs, values, err := collect(selection)
+			So(err, ShouldBeNil)
+			So(cpuIDs, ShouldResemble, []string{allCPU, "0"})
+			So(values[1], ShouldEqual, 100)
+		})
+
+		Convey("baselines of all CPUs should be kept when mode is switched", func() {
+			fixture["proc/stat"] = firstSpreadProcStatSample
+			_, _, err := collect(plugin.Config{"aggregates_only": true})
+			So(err, ShouldBeNil)
+			fixture["proc/stat"] = secondSpreadProcStatSample
+			cpuIDs, values, err := collect(nil)
+			So(err, ShouldBeNil)
+			So(cpuIDs, ShouldResemble, []string{allCPU, "0", "1", "2", "3"})
+			So(values, ShouldResemble, []interface{}{50.0, 0.0, 50.0, 100.0, 50.0})
+		})
+
+		Convey("tasks differing in selection should keep separate baselines when they set task_id", func() {
+			first := plugin.Config{"cpu_list": "0", taskIDConfigKey: "first"}
+			second := plugin.Config{"cpu_list": "1", taskIDConfigKey: "second"}
+			_, _, err := collect(first)
+			So(err, ShouldBeNil)
+			fixture["proc/stat"] = secondSpreadProcStatSample
+			_, _, err = collect(second)
+			So(err, ShouldBeNil)
+			fixture["proc/stat"] = thirdSpreadProcStatSample
+			cpuIDs, values, err := collect(first)
+			So(err, ShouldBeNil)
+			So(cpuIDs, ShouldResemble, []string{allCPU, "0"})
+			// percentages of the first task are calculated since its previous collection
+			So(values[0], ShouldEqual, 100*300.0/800)
+			So(values[1], ShouldEqual, 100*100.0/200)
+		})
+
+		Convey("explicitly requested CPUs should be reported regardless of selection", func() {
+			cfg["aggregates_only"] = true
+			mts, err := p.CollectMetrics([]plugin.Metric{
+				plugin.Metric{Namespace: plugin.NewNamespace(vendor, fs, pluginName, "2", "user_percentage"), Config: cfg},
+			})
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 1)
+		})
+
+		Convey("incorrect selection should be reported", func() {
+			_, _, err := collect(plugin.Config{"cpu_list": "3-1"})
+			So(err, ShouldNotBeNil)
+			_, _, err = collect(plugin.Config{"top_cpus": int64(-1)})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,317 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cpu/procstat"
+)
+
+const (
+	//procDir path of proc directory relative to root directory in snapshots
+	procDir = "proc"
+
+	//procStatFile path of /proc/stat relative to root directory in snapshots
+	procStatFile = procDir + "/stat"
+
+	//hostnameFile path of hostname file relative to root directory in snapshots, read when hostname_from_root is set
+	hostnameFile = "etc/hostname"
+
+	//topologyDir path of directory with topology of CPUs relative to root directory in snapshots
+	topologyDir = "sys/devices/system/cpu"
+)
+
+//snapshotFiles files read by plugin or analyzed together with them which are saved in snapshots and whether they are required
+var snapshotFiles = []struct {
+	path     string
+	required bool
+}{
+	{procStatFile, true},
+	{hostnameFile, false},
+	{CgroupFiles[0], false},
+	{CgroupFiles[1], false},
+	{CgroupFiles[2], false},
+	{CgroupFiles[3], false},
+	{CgroupFiles[4], false},
+}
+
+//CgroupFiles paths of files with CPU usage of cgroup saved in snapshots relative to root directory: cpu.stat of cgroup v2,
+//cpu.stat and cpuacct.usage of controllers of cgroup v1 mounted together and apart; in capture of container
+//they describe cgroup of the container
+var CgroupFiles = []string{
+	"sys/fs/cgroup/cpu.stat",
+	"sys/fs/cgroup/cpu,cpuacct/cpu.stat",
+	"sys/fs/cgroup/cpu,cpuacct/cpuacct.usage",
+	"sys/fs/cgroup/cpu/cpu.stat",
+	"sys/fs/cgroup/cpuacct/cpuacct.usage",
+}
+
+//TopologyFiles names of files with topology of CPU saved in snapshots for each CPU reported by /proc/stat
+var TopologyFiles = []string{"physical_package_id", "core_id"}
+
+//TopologyPath returns path of file with given name describing topology of CPU with given ID
+//relative to root directory in snapshots, e.g. sys/devices/system/cpu/cpu3/topology/core_id
+func TopologyPath(cpuID int, name string) string {
+	return fmt.Sprintf("%s/cpu%d/topology/%s", topologyDir, cpuID, name)
+}
+
+//isTopologyPath tells whether path relative to root directory is path of file saved in snapshots with topology of CPU
+func isTopologyPath(p string) bool {
+	for _, name := range TopologyFiles {
+		if matched, _ := path.Match(topologyDir+"/cpu[0-9]*/topology/"+name, p); matched {
+			return true
+		}
+	}
+	return false
+}
+
+//snapshotFilePath returns path of file saved in snapshots relative to root directory
+//for slash-separated name of file in capture, which may contain leading directory, e.g. sosreport-node-17/proc/stat
+func snapshotFilePath(name string) (string, bool) {
+	for _, file := range snapshotFiles {
+		if name == file.path || strings.HasSuffix(name, "/"+file.path) {
+			return file.path, true
+		}
+	}
+	for p := name; ; {
+		if isTopologyPath(p) {
+			return p, true
+		}
+		i := strings.Index(p, "/")
+		if i < 0 {
+			return "", false
+		}
+		p = p[i+1:]
+	}
+}
+
+//Snapshot contents of files read by plugin taken at the same time,
+//files are keyed by path relative to root directory containing proc directory, e.g. proc/stat
+type Snapshot struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Files     map[string]string `json:"files"`
+}
+
+//TakeSnapshot reads files which plugin reads for given proc directory from filesystem together with
+//topology of CPUs reported by /proc/stat and CPU usage of cgroup, files outside of proc directory are read
+//from its parent, e.g. /hostfs/etc/hostname for /hostfs/proc
+func TakeSnapshot(filesystem FileSystem, procPath string) (Snapshot, error) {
+	snapshot := Snapshot{Timestamp: time.Now(), Files: map[string]string{}}
+	for _, file := range snapshotFiles {
+		if err := snapshot.readFile(filesystem, procPath, file.path, file.required); err != nil {
+			return Snapshot{}, err
+		}
+	}
+	sample, err := procstat.Parse(strings.NewReader(snapshot.Files[procStatFile]))
+	if err != nil {
+		//reported by plugin reading snapshot
+		return snapshot, nil
+	}
+	for _, cpu := range sample.CPUs {
+		for _, name := range TopologyFiles {
+			if err := snapshot.readFile(filesystem, procPath, TopologyPath(cpu.ID, name), false); err != nil {
+				return Snapshot{}, err
+			}
+		}
+	}
+	return snapshot, nil
+}
+
+//readFile reads file with given path relative to root directory into snapshot,
+//missing file is reported only when it is required
+func (s Snapshot) readFile(filesystem FileSystem, procPath string, file string, required bool) error {
+	path := filepath.Join(filepath.Dir(filepath.Clean(procPath)), file)
+	if rel, err := filepath.Rel(procDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		path = filepath.Join(procPath, rel)
+	}
+	content, err := readFile(filesystem, path)
+	if err != nil {
+		if required || !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	s.Files[file] = string(content)
+	return nil
+}
+
+//ReadCapture reads files which plugin reads from captured root directory, e.g. sosreport, or tar archive of it,
+//snapshot is timestamped with modification time of captured /proc/stat
+func ReadCapture(path string) (Snapshot, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if info.IsDir() {
+		snapshot, err := TakeSnapshot(DirFS(path), "/"+procDir)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		statInfo, err := os.Stat(filepath.Join(path, procStatFile))
+		if err != nil {
+			return Snapshot{}, err
+		}
+		snapshot.Timestamp = statInfo.ModTime()
+		return snapshot, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer f.Close()
+	return readTarCapture(f)
+}
+
+//readTarCapture reads files which plugin reads from tar archive (optionally gzipped) of captured root directory,
+//archive may contain leading directory, e.g. sosreport-node-17/proc/stat
+func readTarCapture(r io.Reader) (Snapshot, error) {
+	buffered := bufio.NewReader(r)
+	if magic, err := buffered.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = buffered
+	}
+
+	snapshot := Snapshot{Files: map[string]string{}}
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return Snapshot{}, err
+		}
+		name := strings.TrimPrefix(filepath.ToSlash(filepath.Clean(header.Name)), "/")
+		file, ok := snapshotFilePath(name)
+		if !ok {
+			continue
+		}
+		content, err := ioutil.ReadAll(archive)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		snapshot.Files[file] = string(content)
+		if file == procStatFile {
+			snapshot.Timestamp = header.ModTime
+		}
+	}
+	for _, file := range snapshotFiles {
+		if _, ok := snapshot.Files[file.path]; file.required && !ok {
+			return Snapshot{}, fmt.Errorf("Archive misses %s", file.path)
+		}
+	}
+	return snapshot, nil
+}
+
+//Open opens file of snapshot, names are relative to root directory of snapshot even if they are absolute,
+//so plugin reads snapshot with default proc_path as if it was live
+func (s Snapshot) Open(name string) (io.ReadCloser, error) {
+	return MapFS(s.Files).Open(name)
+}
+
+//Replay filesystem serving files of the current snapshot of session, which is advanced by replaying tool
+type Replay struct {
+	mutex    sync.Mutex
+	snapshot Snapshot
+}
+
+//Advance makes given snapshot the current one
+func (r *Replay) Advance(snapshot Snapshot) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.snapshot = snapshot
+}
+
+//Now returns time at which the current snapshot was taken
+func (r *Replay) Now() time.Time {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.snapshot.Timestamp
+}
+
+//Open opens file of the current snapshot
+func (r *Replay) Open(name string) (io.ReadCloser, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.snapshot.Open(name)
+}
+
+//SessionWriter writes snapshots to session archive, which is gzipped stream of JSON objects, one per snapshot
+type SessionWriter struct {
+	gz      *gzip.Writer
+	encoder *json.Encoder
+}
+
+//NewSessionWriter creates writer of session archive
+func NewSessionWriter(w io.Writer) *SessionWriter {
+	gz := gzip.NewWriter(w)
+	return &SessionWriter{gz: gz, encoder: json.NewEncoder(gz)}
+}
+
+//Write appends snapshot to archive
+func (s *SessionWriter) Write(snapshot Snapshot) error {
+	return s.encoder.Encode(snapshot)
+}
+
+//Close flushes archive, underlying writer is not closed
+func (s *SessionWriter) Close() error {
+	return s.gz.Close()
+}
+
+//ReadSession reads all snapshots from session archive in the order they were taken
+func ReadSession(r io.Reader) ([]Snapshot, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	snapshots := []Snapshot{}
+	decoder := json.NewDecoder(gz)
+	for {
+		snapshot := Snapshot{}
+		if err := decoder.Decode(&snapshot); err == io.EOF {
+			return snapshots, nil
+		} else if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+}
@@ -0,0 +1,158 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//recordedSession session recorded from a single-CPU virtual machine by record mode of plugin binary
+const recordedSession = "testdata/session.jsonl.gz"
+
+func TestSession(t *testing.T) {
+	Convey("Given root directory with proc directory", t, func() {
+		root, err := ioutil.TempDir("", "snap-plugin-collector-cpu")
+		So(err, ShouldBeNil)
+		Reset(func() { os.RemoveAll(root) })
+		procPath := filepath.Join(root, "proc")
+		So(os.Mkdir(procPath, 0755), ShouldBeNil)
+		writeProcStat(procPath, firstProcStatSample)
+
+		Convey("snapshots should survive archive round trip", func() {
+			first, err := TakeSnapshot(LiveFS, procPath)
+			So(err, ShouldBeNil)
+			So(first.Files, ShouldResemble, map[string]string{procStatFile: firstProcStatSample})
+
+			So(os.Mkdir(filepath.Join(root, "etc"), 0755), ShouldBeNil)
+			So(ioutil.WriteFile(filepath.Join(root, hostnameFile), []byte("node-17\n"), 0644), ShouldBeNil)
+			writeProcStat(procPath, secondProcStatSample)
+			second, err := TakeSnapshot(LiveFS, procPath)
+			So(err, ShouldBeNil)
+			So(second.Files[hostnameFile], ShouldEqual, "node-17\n")
+
+			archive := &bytes.Buffer{}
+			session := NewSessionWriter(archive)
+			So(session.Write(first), ShouldBeNil)
+			So(session.Write(second), ShouldBeNil)
+			So(session.Close(), ShouldBeNil)
+
+			snapshots, err := ReadSession(archive)
+			So(err, ShouldBeNil)
+			So(len(snapshots), ShouldEqual, 2)
+			So(snapshots[0].Files, ShouldResemble, first.Files)
+			So(snapshots[1].Files, ShouldResemble, second.Files)
+			So(snapshots[1].Timestamp.Equal(second.Timestamp), ShouldBeTrue)
+		})
+
+		Convey("snapshot should be read as filesystem", func() {
+			snapshot := Snapshot{Timestamp: time.Now(), Files: map[string]string{procStatFile: firstProcStatSample}}
+			content, err := readFile(snapshot, "/proc/stat")
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, firstProcStatSample)
+			_, err = readFile(snapshot, "/etc/hostname")
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("topology of CPUs and cgroup usage should be saved in snapshots", func() {
+			files := map[string]string{
+				TopologyPath(0, "physical_package_id"): "0\n",
+				TopologyPath(0, "core_id"):             "3\n",
+				TopologyPath(1, "core_id"):             "4\n",
+				CgroupFiles[0]:                         "usage_usec 100\n",
+			}
+			for path, content := range files {
+				So(os.MkdirAll(filepath.Join(root, filepath.Dir(path)), 0755), ShouldBeNil)
+				So(ioutil.WriteFile(filepath.Join(root, path), []byte(content), 0644), ShouldBeNil)
+			}
+			snapshot, err := TakeSnapshot(LiveFS, procPath)
+			So(err, ShouldBeNil)
+			// only CPUs reported by /proc/stat are saved
+			delete(files, TopologyPath(1, "core_id"))
+			files[procStatFile] = firstProcStatSample
+			So(snapshot.Files, ShouldResemble, files)
+		})
+
+		Convey("topology of CPUs should be read from capture archive", func() {
+			archive := &bytes.Buffer{}
+			tw := tar.NewWriter(archive)
+			for _, name := range []string{"sosreport/proc/stat", "sosreport/" + TopologyPath(12, "core_id"), "sosreport/sys/devices/system/cpu/cpu12/online"} {
+				So(tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 1}), ShouldBeNil)
+				_, err := tw.Write([]byte("1"))
+				So(err, ShouldBeNil)
+			}
+			So(tw.Close(), ShouldBeNil)
+			snapshot, err := readTarCapture(archive)
+			So(err, ShouldBeNil)
+			So(snapshot.Files, ShouldResemble, map[string]string{procStatFile: "1", TopologyPath(12, "core_id"): "1"})
+		})
+
+		Convey("missing /proc/stat should be reported", func() {
+			_, err := TakeSnapshot(LiveFS, filepath.Join(root, "missing", "proc"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given session recorded on real machine", t, func() {
+		f, err := os.Open(recordedSession)
+		So(err, ShouldBeNil)
+		defer f.Close()
+		snapshots, err := ReadSession(f)
+		So(err, ShouldBeNil)
+		So(len(snapshots), ShouldEqual, 5)
+
+		Convey("replayed percentages should add up to 100", func() {
+			replayed := &Replay{}
+			p := NewWithFileSystem(replayed)
+			for i, snapshot := range snapshots {
+				replayed.Advance(snapshot)
+				cfg := plugin.Config{"proc_path": "/proc", "hostname_from_root": true}
+				request := []plugin.Metric{}
+				for _, column := range procStatColumns {
+					request = append(request, plugin.Metric{
+						Namespace: plugin.NewNamespace(vendor, fs, pluginName, allCPU, getNamespaceMetricPart(column, percentageRepresentationType)),
+						Config:    cfg,
+					})
+				}
+				mts, err := p.CollectMetrics(request)
+				So(err, ShouldBeNil)
+				if i == 0 {
+					continue
+				}
+				sum := 0.0
+				for _, mt := range mts {
+					sum += mt.Data.(float64)
+					So(mt.Tags[hostnameTag], ShouldEqual, "vm")
+				}
+				So(sum, ShouldAlmostEqual, 100, 0.001)
+			}
+		})
+	})
+}
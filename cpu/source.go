@@ -0,0 +1,373 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+const (
+	//maxSourcesNumber max number of sources kept by plugin, the least recently used one is dropped when exceeded
+	maxSourcesNumber = 16
+
+	//sourceTag tag with name of procfs root metric was read from, set when task collects from several roots
+	sourceTag = "source"
+)
+
+//procRoot named procfs root given in proc_paths config item
+type procRoot struct {
+	name string
+	path string
+}
+
+//sourceConfig config items which define source, tasks with the same source config share the source
+type sourceConfig struct {
+	procPath             string // path to stat file
+	reportUnknownColumns bool
+	hostnameFromRoot     bool            // read hostname from etc/hostname of root directory containing proc directory
+	samplingInterval     time.Duration   // sub-interval of background sampler, zero when sampler is disabled
+	samplingBuffer       int             // number of samples kept by background sampler
+	rollingWindows       []time.Duration // lengths of windows of rolling averages, empty when they are disabled
+	stateDir             string          // directory where baselines are persisted, empty when they are not
+	warmup               time.Duration   // length of warm-up sample taken when baseline is missing, zero when disabled
+}
+
+//source /proc/stat file read by plugin together with its format and sampling baselines of tasks reading it
+type source struct {
+	sourceConfig
+	filesystem           FileSystem
+	procStatMetricsNames []string
+	snapMetricsNames     []string
+	metrics              map[string]metricRef // ways of calculating metrics keyed by their names
+	hostname             string               // hostname read from root directory, empty if not requested
+	sampler              *sampler             // background sampler, nil unless sampling_interval is set
+	baselines            *baselineStore       // persisted baselines of tasks, nil unless state_dir is set
+	mutex                sync.Mutex
+	states               map[string]*sampleState
+	statesClock          uint64 // incremented on every use of baseline to find the least recently used one
+	lastUsed             uint64
+}
+
+//getSourceConfig reads source config items from task config
+func getSourceConfig(cfg plugin.Config) (sourceConfig, error) {
+	srcCfg := sourceConfig{
+		procPath:             cpuInfo,
+		reportUnknownColumns: true,
+		samplingBuffer:       defaultSamplingBuffer,
+	}
+	if procPath, err := cfg.GetString("proc_path"); err == nil {
+		srcCfg.procPath = filepath.Join(procPath, "stat")
+	}
+	if report, err := cfg.GetBool("report_unknown_columns"); err == nil {
+		srcCfg.reportUnknownColumns = report
+	}
+	if fromRoot, err := cfg.GetBool("hostname_from_root"); err == nil {
+		srcCfg.hostnameFromRoot = fromRoot
+	}
+	if interval, err := cfg.GetString("sampling_interval"); err == nil {
+		if srcCfg.samplingInterval, err = parseSamplingInterval(interval); err != nil {
+			return sourceConfig{}, err
+		}
+	}
+	if size, err := cfg.GetInt("sampling_buffer"); err == nil {
+		if size < 2 || size > maxSamplingBuffer {
+			return sourceConfig{}, fmt.Errorf("Incorrect sampling_buffer {%d}, expected between 2 and %d", size, maxSamplingBuffer)
+		}
+		srcCfg.samplingBuffer = int(size)
+	}
+	if stateDir, err := cfg.GetString("state_dir"); err == nil {
+		srcCfg.stateDir = stateDir
+	}
+	if warmup, err := cfg.GetString("warmup"); err == nil {
+		if srcCfg.warmup, err = parseWarmup(warmup); err != nil {
+			return sourceConfig{}, err
+		}
+	}
+	if windows, err := cfg.GetString("rolling_windows"); err == nil {
+		if srcCfg.rollingWindows, err = parseRollingWindows(windows); err != nil {
+			return sourceConfig{}, err
+		}
+	}
+	return srcCfg, nil
+}
+
+//key returns identifier of source described by config
+func (c sourceConfig) key() string {
+	key := fmt.Sprintf("%s;report_unknown_columns=%v;hostname_from_root=%v",
+		c.procPath, c.reportUnknownColumns, c.hostnameFromRoot)
+	if c.samplingInterval > 0 {
+		key += fmt.Sprintf(";sampling_interval=%v;sampling_buffer=%d", c.samplingInterval, c.samplingBuffer)
+	}
+	if len(c.rollingWindows) > 0 {
+		key += fmt.Sprintf(";rolling_windows=%v", c.rollingWindows)
+	}
+	if c.stateDir != "" {
+		key += ";state_dir=" + c.stateDir
+	}
+	if c.warmup > 0 {
+		key += fmt.Sprintf(";warmup=%v", c.warmup)
+	}
+	return key
+}
+
+//parseProcPaths parses list of named procfs roots given as name=path pairs separated by commas,
+//e.g. "host=/proc,sidecar=/var/lib/sidecar/proc"
+func parseProcPaths(procPaths string) ([]procRoot, error) {
+	names, paths, err := parseNamedList(procPaths, "proc_paths")
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("Empty proc_paths")
+	}
+	roots := make([]procRoot, len(names))
+	for i, name := range names {
+		roots[i] = procRoot{name: name, path: paths[name]}
+	}
+	return roots, nil
+}
+
+//parseNamedList parses list of name=value pairs separated by commas given in config item,
+//names are returned in the order of appearance
+func parseNamedList(list string, configKey string) ([]string, map[string]string, error) {
+	names := []string{}
+	values := map[string]string{}
+	for _, item := range strings.Split(list, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		pair := strings.SplitN(item, "=", 2)
+		if len(pair) != 2 || strings.TrimSpace(pair[0]) == "" || strings.TrimSpace(pair[1]) == "" {
+			return nil, nil, fmt.Errorf("Incorrect %s item {%s}, expected name=value", configKey, item)
+		}
+		name := strings.TrimSpace(pair[0])
+		if _, ok := values[name]; ok {
+			return nil, nil, fmt.Errorf("Duplicated %s name {%s}", configKey, name)
+		}
+		names = append(names, name)
+		values[name] = strings.TrimSpace(pair[1])
+	}
+	return names, values, nil
+}
+
+//getSources returns sources described by given task config together with their names,
+//names are empty unless task collects from several procfs roots given in proc_paths
+func (p *Plugin) getSources(cfg plugin.Config) ([]*source, []string, error) {
+	procPaths, err := cfg.GetString("proc_paths")
+	if err != nil {
+		src, err := p.getSource(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []*source{src}, []string{""}, nil
+	}
+
+	roots, err := parseProcPaths(procPaths)
+	if err != nil {
+		return nil, nil, err
+	}
+	sources := make([]*source, len(roots))
+	names := make([]string, len(roots))
+	for i, root := range roots {
+		//proc_paths takes precedence over proc_path
+		rootCfg := plugin.Config{}
+		for key, value := range cfg {
+			rootCfg[key] = value
+		}
+		rootCfg["proc_path"] = root.path
+		if sources[i], err = p.getSource(rootCfg); err != nil {
+			return nil, nil, fmt.Errorf("Cannot read proc_paths item {%s}: %v", root.name, err)
+		}
+		names[i] = root.name
+	}
+	return sources, names, nil
+}
+
+//getSource returns source described by given task config, reading format of its /proc/stat file if needed
+func (p *Plugin) getSource(cfg plugin.Config) (*source, error) {
+	srcCfg, err := getSourceConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	key := srcCfg.key()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.sources == nil {
+		p.sources = make(map[string]*source)
+	}
+	src, ok := p.sources[key]
+	if !ok {
+		if src, err = newSource(srcCfg, p.filesystem); err != nil {
+			return nil, err
+		}
+		if len(p.sources) >= maxSourcesNumber {
+			p.dropLeastRecentlyUsedSource()
+		}
+		p.sources[key] = src
+	}
+	p.sourcesClock++
+	src.lastUsed = p.sourcesClock
+	return src, nil
+}
+
+//dropLeastRecentlyUsedSource removes source which was not used for the longest time together with its baselines,
+//p.mutex must be held
+func (p *Plugin) dropLeastRecentlyUsedSource() {
+	var oldestKey string
+	var oldest uint64
+	for key, src := range p.sources {
+		if oldestKey == "" || src.lastUsed < oldest {
+			oldestKey, oldest = key, src.lastUsed
+		}
+	}
+	p.sources[oldestKey].close()
+	delete(p.sources, oldestKey)
+}
+
+//Close stops background samplers of all sources and drops the sources together with their baselines,
+//plugin may still be used afterwards
+func (p *Plugin) Close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for key, src := range p.sources {
+		src.close()
+		delete(p.sources, key)
+	}
+}
+
+//close stops background sampler of source if it is running and persists samples of tasks
+//which were not persisted yet, so that restart of plugin doesn't lose them
+func (s *source) close() {
+	if s.sampler != nil {
+		s.sampler.close()
+	}
+	if s.baselines == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for key, state := range s.states {
+		state.mutex.Lock()
+		if state.samples > 0 && state.currTime.After(state.savedTime) {
+			if err := s.baselines.save(key, state); err != nil {
+				fmt.Fprintf(os.Stderr, "Cannot persist baseline of %s: %v\n", s.procPath, err)
+			}
+		}
+		state.mutex.Unlock()
+	}
+}
+
+//newSource creates source described by config which is read from given filesystem,
+//number of CPUs and columns is read from its /proc/stat file
+func newSource(cfg sourceConfig, filesystem FileSystem) (*source, error) {
+	src := &source{
+		sourceConfig: cfg,
+		filesystem:   filesystem,
+		states:       make(map[string]*sampleState),
+	}
+	procStatMetricsNumber, err := getInitialProcStatData(src.filesystem, src.procPath)
+	if err != nil {
+		return nil, err
+	}
+
+	//initialize metric names arrays
+	src.procStatMetricsNames = getProcStatMetricsNames(procStatMetricsNumber)
+	if procStatMetricsNumber > len(procStatColumns) {
+		action := "reported as generic " + unknownColumnPrefix + "<N> metrics"
+		if !src.reportUnknownColumns {
+			action = "ignored"
+		}
+		fmt.Fprintf(os.Stderr, "%s reports %d CPU columns while %d are known to plugin, extra columns are %s\n",
+			src.procPath, procStatMetricsNumber, len(procStatColumns), action)
+	}
+	snapSpecificMetricsNames := []string{activeProcStat, utilizationProcStat}
+
+	//build snapMetricsNames to support different kernels
+	for i, name := range src.procStatMetricsNames {
+		if i >= len(procStatColumns) && !src.reportUnknownColumns {
+			break
+		}
+		src.snapMetricsNames = append(src.snapMetricsNames, name)
+	}
+	src.snapMetricsNames = append(src.snapMetricsNames, snapSpecificMetricsNames...)
+	if src.samplingInterval > 0 {
+		src.sampler = newSampler(src.filesystem, src.procPath, procStatMetricsNumber,
+			src.samplingInterval, src.samplingBuffer)
+	}
+	src.metrics = getMetricRefs(src.snapMetricsNames, src.procStatMetricsNames, src.sampler != nil, src.rollingWindows)
+
+	if src.stateDir != "" {
+		if src.baselines, err = newBaselineStore(src.stateDir, src.filesystem, src.procPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot persist baselines of %s in %s, percentages are not available in the first collection after restart: %v\n",
+				src.procPath, src.stateDir, err)
+		}
+	}
+	if src.hostnameFromRoot {
+		if src.hostname, err = readRootHostname(src.filesystem, src.procPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot read hostname for %s, hostname of plugin host is used instead: %v\n", src.procPath, err)
+		}
+	}
+	return src, nil
+}
+
+//getState returns sampling baseline for given key, creating it if needed
+func (s *source) getState(key string) *sampleState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state, ok := s.states[key]
+	if !ok {
+		if len(s.states) >= maxStatesNumber {
+			s.dropLeastRecentlyUsedState()
+		}
+		state = newSampleState()
+		s.states[key] = state
+	}
+	s.statesClock++
+	state.lastUsed = s.statesClock
+	return state
+}
+
+//dropLeastRecentlyUsedState removes baseline which was not used for the longest time together with
+//its persisted copy, s.mutex must be held
+func (s *source) dropLeastRecentlyUsedState() {
+	var oldestKey string
+	var oldest uint64
+	for key, state := range s.states {
+		if oldestKey == "" || state.lastUsed < oldest {
+			oldestKey, oldest = key, state.lastUsed
+		}
+	}
+	delete(s.states, oldestKey)
+	if s.baselines != nil {
+		if err := s.baselines.remove(oldestKey); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot remove baseline of %s: %v\n", s.procPath, err)
+		}
+	}
+}
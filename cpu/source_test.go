@@ -0,0 +1,200 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	hostProcStatSample = `cpu  100 0 100 800 0 0 0 0 0 0
+cpu0 100 0 100 800 0 0 0 0 0 0`
+
+	containerProcStatSample = `cpu  300 0 300 400 0 0 0 0
+cpu0 150 0 150 200 0 0 0 0
+cpu1 150 0 150 200 0 0 0 0`
+)
+
+func TestSources(t *testing.T) {
+	Convey("Given cpu plugin serving tasks with different proc_path", t, func() {
+		root, err := ioutil.TempDir("", "snap-plugin-collector-cpu")
+		So(err, ShouldBeNil)
+		Reset(func() { os.RemoveAll(root) })
+
+		hostProc := filepath.Join(root, "proc")
+		containerProc := filepath.Join(root, "hostproc")
+		So(os.Mkdir(hostProc, 0755), ShouldBeNil)
+		So(os.Mkdir(containerProc, 0755), ShouldBeNil)
+		writeProcStat(hostProc, hostProcStatSample)
+		writeProcStat(containerProc, containerProcStatSample)
+
+		p := New()
+
+		Convey("each task should get data from its own proc_path", func() {
+			mts, err := p.CollectMetrics(newTaskMetricTypes(hostProc, ""))
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 1)
+
+			jiffies := func(procPath string) interface{} {
+				mts, err := p.CollectMetrics([]plugin.Metric{
+					plugin.Metric{
+						Namespace: plugin.NewNamespace(vendor, fs, pluginName, allCPU, getNamespaceMetricPart(userProcStat, jiffiesRepresentationType)),
+						Config:    newTaskMetricTypes(procPath, "")[0].Config,
+					},
+				})
+				So(err, ShouldBeNil)
+				return mts[0].Data
+			}
+			So(jiffies(hostProc), ShouldEqual, 100)
+			So(jiffies(containerProc), ShouldEqual, 300)
+			So(jiffies(hostProc), ShouldEqual, 100)
+
+			hostSrc, err := p.getSource(newTaskMetricTypes(hostProc, "")[0].Config)
+			So(err, ShouldBeNil)
+			containerSrc, err := p.getSource(newTaskMetricTypes(containerProc, "")[0].Config)
+			So(err, ShouldBeNil)
+			So(hostSrc, ShouldNotEqual, containerSrc)
+			So(len(containerSrc.procStatMetricsNames), ShouldEqual, 8)
+		})
+
+		Convey("metrics requested with different proc_path in one call should be collected from their sources", func() {
+			request := append(newTaskMetricTypes(hostProc, ""), newTaskMetricTypes(containerProc, "")...)
+			request[1].Namespace = plugin.NewNamespace(vendor, fs, pluginName, "*", getNamespaceMetricPart(userProcStat, jiffiesRepresentationType))
+			mts, err := p.CollectMetrics(request)
+			So(err, ShouldBeNil)
+			// user_percentage of all from host (without value in the first collection), user_jiffies of all, 0 and 1 from container
+			So(len(mts), ShouldEqual, 4)
+			So(len(p.sources), ShouldEqual, 2)
+		})
+
+		Convey("number of kept sources should be bounded", func() {
+			for i := 0; i < maxSourcesNumber+3; i++ {
+				procPath := filepath.Join(root, strconv.Itoa(i))
+				So(os.Mkdir(procPath, 0755), ShouldBeNil)
+				writeProcStat(procPath, hostProcStatSample)
+				_, err := p.getSource(plugin.Config{"proc_path": procPath})
+				So(err, ShouldBeNil)
+			}
+			So(len(p.sources), ShouldEqual, maxSourcesNumber)
+			So(p.sources, ShouldNotContainKey, sourceKey(plugin.Config{"proc_path": filepath.Join(root, "0")}))
+		})
+
+		Convey("invalid proc_path should be reported and not cached", func() {
+			_, err := p.CollectMetrics(newTaskMetricTypes(filepath.Join(root, "missing"), ""))
+			So(err, ShouldNotBeNil)
+			So(len(p.sources), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestProcPaths(t *testing.T) {
+	Convey("Given list of named procfs roots", t, func() {
+		Convey("correct list should be parsed", func() {
+			roots, err := parseProcPaths("host=/proc, sidecar = /var/lib/sidecar/proc,")
+			So(err, ShouldBeNil)
+			So(roots, ShouldResemble, []procRoot{
+				procRoot{name: "host", path: "/proc"},
+				procRoot{name: "sidecar", path: "/var/lib/sidecar/proc"},
+			})
+		})
+
+		Convey("incorrect list should be reported", func() {
+			_, err := parseProcPaths("")
+			So(err, ShouldNotBeNil)
+			_, err = parseProcPaths("/proc")
+			So(err, ShouldNotBeNil)
+			_, err = parseProcPaths("host=")
+			So(err, ShouldNotBeNil)
+			_, err = parseProcPaths("host=/proc,host=/hostproc")
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given cpu plugin serving task which collects from several procfs roots", t, func() {
+		root, err := ioutil.TempDir("", "snap-plugin-collector-cpu")
+		So(err, ShouldBeNil)
+		Reset(func() { os.RemoveAll(root) })
+
+		hostProc := filepath.Join(root, "proc")
+		containerProc := filepath.Join(root, "hostproc")
+		So(os.Mkdir(hostProc, 0755), ShouldBeNil)
+		So(os.Mkdir(containerProc, 0755), ShouldBeNil)
+		writeProcStat(hostProc, hostProcStatSample)
+		writeProcStat(containerProc, containerProcStatSample)
+
+		p := New()
+		cfg := plugin.Config{"proc_paths": "host=" + hostProc + ",sidecar=" + containerProc}
+
+		Convey("metrics should be collected from each root and tagged with its name", func() {
+			mts, err := p.CollectMetrics([]plugin.Metric{
+				plugin.Metric{
+					Namespace: plugin.NewNamespace(vendor, fs, pluginName, allCPU, getNamespaceMetricPart(userProcStat, jiffiesRepresentationType)),
+					Config:    cfg,
+				},
+			})
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 2)
+
+			values := map[string]interface{}{}
+			for _, mt := range mts {
+				values[mt.Tags[sourceTag]] = mt.Data
+			}
+			So(values["host"], ShouldEqual, 100)
+			So(values["sidecar"], ShouldEqual, 300)
+		})
+
+		Convey("metric types should cover all roots", func() {
+			mts, err := p.GetMetricTypes(cfg)
+			So(err, ShouldBeNil)
+			// host reports 10 columns while sidecar reports only 8
+			So(len(mts), ShouldEqual, 12*4+1)
+		})
+
+		Convey("unreadable root should be reported with its name", func() {
+			cfg["proc_paths"] = "host=" + hostProc + ",missing=" + filepath.Join(root, "missing")
+			_, err := p.CollectMetrics([]plugin.Metric{
+				plugin.Metric{
+					Namespace: plugin.NewNamespace(vendor, fs, pluginName, allCPU, getNamespaceMetricPart(userProcStat, jiffiesRepresentationType)),
+					Config:    cfg,
+				},
+			})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "missing")
+		})
+	})
+}
+
+//sourceKey returns key of source described by task config
+func sourceKey(cfg plugin.Config) string {
+	srcCfg, err := getSourceConfig(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return srcCfg.key()
+}
@@ -0,0 +1,263 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cpu/procstat"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+const (
+	//maxStatesNumber max number of sampling baselines kept by plugin, the least recently used one is dropped when exceeded
+	maxStatesNumber = 64
+
+	//taskIDConfigKey config item which separates baselines of tasks requesting the same metrics with the same config
+	taskIDConfigKey = "task_id"
+)
+
+//sampleState sampling baseline of a single task, percentages are calculated
+//against the previous sample taken for the same task; memory of both samples is reused by following ones
+type sampleState struct {
+	mutex    sync.Mutex
+	parser   procstat.Parser
+	curr     procstat.Sample
+	prev     procstat.Sample
+	samples  int // number of samples read, percentages need at least two of them
+	currTime time.Time
+	prevTime time.Time
+	lastUsed uint64
+	//savedTime time of the last persisted sample, zero when none was persisted
+	savedTime time.Time
+	//collectedAt time of the previous collection and interval between the two previous ones
+	collectedAt time.Time
+	interval    time.Duration
+	//irregular score of collections at irregular intervals, it grows with irregular ones and decays with regular ones
+	irregular int
+	//collisionReported set once tasks sharing baseline were reported
+	collisionReported bool
+	//sampledSeq sequence number of the last sample of background sampler seen by the previous collection
+	sampledSeq uint64
+	//window samples of background sampler taken since the previous collection, valid during collection only
+	window []*procstat.Sample
+	//values scratch buffer of sampled percentages reused between metrics
+	values []float64
+	//spread scratch buffer of percentages of CPUs reused between statistics across CPUs
+	spread []float64
+	//rolling history of counters for rolling averages, nil until they are requested
+	rolling *rollingHistory
+}
+
+//collisionScore score of irregular collection intervals at which tasks are suspected to share baseline
+const collisionScore = 3
+
+//newSampleState creates empty sampling baseline
+func newSampleState() *sampleState {
+	return &sampleState{}
+}
+
+//read reads new sample of /proc/stat with given number of columns, lines of individual CPUs are read
+//only when perCPU is set; the current sample becomes the previous one and samples are left untouched when reading fails
+func (st *sampleState) read(filesystem FileSystem, path string, columns int, perCPU bool) error {
+	st.curr, st.prev = st.prev, st.curr
+	now := readTime(filesystem)
+	if err := readSample(filesystem, path, &st.parser, &st.curr, columns, perCPU); err != nil {
+		st.curr, st.prev = st.prev, st.curr
+		return err
+	}
+	st.currTime, st.prevTime = now, st.currTime
+	st.samples++
+	return nil
+}
+
+//observeCollection records time of collection and tells, only once, whether collections come at irregular
+//intervals (an interval more than twice as long or short as the previous one); this is what happens when
+//tasks with different schedules share baseline, each of them then gets percentages over part of its interval
+func (st *sampleState) observeCollection(now time.Time) bool {
+	if !st.collectedAt.IsZero() {
+		interval := now.Sub(st.collectedAt)
+		if st.interval > 0 && (interval*2 < st.interval || interval > st.interval*2) {
+			st.irregular++
+		} else if st.irregular > 0 {
+			st.irregular--
+		}
+		st.interval = interval
+	}
+	st.collectedAt = now
+	if st.irregular < collisionScore || st.collisionReported {
+		return false
+	}
+	st.collisionReported = true
+	return true
+}
+
+//readSample reads sample of /proc/stat with given number of columns using parser, lines of individual CPUs
+//are read only when perCPU is set; all CPUs online at the time are read, so that CPUs brought online or offline
+//after source was created are taken into account; sample is left in undefined state when reading fails
+func readSample(filesystem FileSystem, path string, parser *procstat.Parser, sample *procstat.Sample, columns int, perCPU bool) error {
+	fh, err := filesystem.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	if perCPU {
+		err = parser.Parse(fh, sample)
+	} else {
+		err = parser.ParseAggregate(fh, sample)
+	}
+	if err != nil {
+		return err
+	}
+	if sample.Columns != columns {
+		return fmt.Errorf("Wrong data length. Expected {%d} is {%d}", columns, sample.Columns)
+	}
+	return nil
+}
+
+//value returns value of metric for CPU at given position of current sample (all CPUs when i is negative),
+//percentages are nil when CPU was not present in the previous sample
+func (st *sampleState) value(ref metricRef, i int, id int) interface{} {
+	if ref.statistic != "" {
+		return st.sampledValue(ref, i, id)
+	}
+	if ref.window > 0 {
+		return st.rollingValue(ref, i, id, st.currTime)
+	}
+	seconds := st.currTime.Sub(st.prevTime).Seconds()
+	if i < 0 {
+		return ref.value(st.curr.All, st.prevAll(), seconds)
+	}
+	return ref.value(st.curr.CPUs[i].Times, st.prevCPU(i, id), seconds)
+}
+
+//position returns position in current sample and numeric identifier of CPU with given identifier,
+//position is negative for all CPUs together
+func (st *sampleState) position(cpuID string) (int, int, bool) {
+	if cpuID == allCPU {
+		return -1, -1, true
+	}
+	id, err := strconv.Atoi(cpuID)
+	if err != nil {
+		return 0, 0, false
+	}
+	for i, cpu := range st.curr.CPUs {
+		if cpu.ID == id {
+			return i, id, true
+		}
+	}
+	return 0, 0, false
+}
+
+//prevAll returns previous times of all CPUs together or nil when there is no previous sample
+func (st *sampleState) prevAll() *procstat.CPUTimes {
+	if st.samples < 2 {
+		return nil
+	}
+	return &st.prev.All
+}
+
+//prevCPU returns previous times of CPU at given position of current sample or nil when they are not known
+func (st *sampleState) prevCPU(i int, id int) *procstat.CPUTimes {
+	if st.samples < 2 {
+		return nil
+	}
+	return findCPU(st.prev.CPUs, i, id)
+}
+
+//findCPU returns times of CPU with given identifier among times of CPUs or nil when it is not there,
+//CPU is usually found at the same position i as in other samples
+func findCPU(cpus []procstat.CPUStat, i int, id int) *procstat.CPUTimes {
+	if i < len(cpus) && cpus[i].ID == id {
+		return &cpus[i].Times
+	}
+	for j := range cpus {
+		if cpus[j].ID == id {
+			return &cpus[j].Times
+		}
+	}
+	return nil
+}
+
+//sampledValue returns statistic of metric over percentages of CPU at given position of current sample
+//(all CPUs when i is negative) calculated for each sub-interval of window of background sampler,
+//sub-intervals with invalid data are skipped and value is nil when there are no valid ones;
+//sub-intervals are uneven (sampler is late or collection comes between samples), so average is weighted
+//by jiffies elapsed in each of them, which makes it the percentage over all valid sub-intervals
+func (st *sampleState) sampledValue(ref metricRef, i int, id int) interface{} {
+	st.values = st.values[:0]
+	var spent, elapsed int64
+	var prev *procstat.CPUTimes
+	for _, sample := range st.window {
+		curr := &sample.All
+		if i >= 0 {
+			curr = findCPU(sample.CPUs, i, id)
+		}
+		if curr != nil && prev != nil {
+			delta := curr.Delta(*prev)
+			value := ref.diff(delta)
+			if percent, ok := delta.Percent(value); ok {
+				st.values = append(st.values, percent)
+				spent += value
+				elapsed += delta.Total()
+			}
+		}
+		prev = curr
+	}
+	if len(st.values) == 0 {
+		return nil
+	}
+	if ref.statistic == percentageAvgRepresentationType {
+		return 100 * float64(spent) / float64(elapsed)
+	}
+	return percentStatistic(ref.statistic, st.values)
+}
+
+//getStateKey builds key identifying task which requested metrics,
+//tasks are told apart by requested namespaces and their config (including optional task_id)
+//except for config items selecting reported CPUs
+func getStateKey(metricTypes []plugin.Metric) string {
+	requests := make([]string, 0, len(metricTypes))
+	for _, metricType := range metricTypes {
+		request := metricType.Namespace.String()
+		cfg := metricType.Config
+		keys := make([]string, 0, len(cfg))
+		for key := range cfg {
+			if !selectionConfigKeys[key] {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			request += fmt.Sprintf(";%s=%v", key, cfg[key])
+		}
+		requests = append(requests, request)
+	}
+	sort.Strings(requests)
+	return strings.Join(requests, "|")
+}
@@ -0,0 +1,354 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	firstProcStatSample = `cpu  100 0 100 800 0 0 0 0 0 0
+cpu0 100 0 100 800 0 0 0 0 0 0`
+
+	secondProcStatSample = `cpu  150 0 150 900 0 0 0 0 0 0
+cpu0 150 0 150 900 0 0 0 0 0 0`
+)
+
+//writeProcStat writes mocked stat file to given proc directory
+func writeProcStat(procPath string, content string) {
+	if err := ioutil.WriteFile(filepath.Join(procPath, "stat"), []byte(content), 0644); err != nil {
+		panic(err)
+	}
+}
+
+//newTaskMetricTypes builds metric types requested by task with given proc_path and task_id
+func newTaskMetricTypes(procPath string, taskID string) []plugin.Metric {
+	cfg := plugin.Config{"proc_path": procPath}
+	if taskID != "" {
+		cfg[taskIDConfigKey] = taskID
+	}
+	return []plugin.Metric{
+		plugin.Metric{
+			Namespace: plugin.NewNamespace(vendor, fs, pluginName, allCPU, getNamespaceMetricPart(userProcStat, percentageRepresentationType)),
+			Config:    cfg,
+		},
+	}
+}
+
+func TestPerTaskStates(t *testing.T) {
+	Convey("Given cpu plugin serving two tasks", t, func() {
+		procPath, err := ioutil.TempDir("", "snap-plugin-collector-cpu")
+		So(err, ShouldBeNil)
+		Reset(func() { os.RemoveAll(procPath) })
+
+		writeProcStat(procPath, firstProcStatSample)
+		p := New()
+		fastTask := newTaskMetricTypes(procPath, "fast")
+		slowTask := newTaskMetricTypes(procPath, "slow")
+
+		Convey("tasks should get separate baselines", func() {
+			So(getStateKey(fastTask), ShouldNotEqual, getStateKey(slowTask))
+			So(getStateKey(fastTask), ShouldEqual, getStateKey(newTaskMetricTypes(procPath, "fast")))
+		})
+
+		Convey("collection by one task should not reset baseline of another", func() {
+			mts, err := p.CollectMetrics(slowTask)
+			So(err, ShouldBeNil)
+			So(mts[0].Data, ShouldBeNil)
+
+			mts, err = p.CollectMetrics(fastTask)
+			So(err, ShouldBeNil)
+			So(mts[0].Data, ShouldBeNil)
+
+			writeProcStat(procPath, secondProcStatSample)
+			mts, err = p.CollectMetrics(fastTask)
+			So(err, ShouldBeNil)
+			So(mts[0].Data, ShouldEqual, 25)
+
+			// fast task has already seen the second sample, slow one has not
+			mts, err = p.CollectMetrics(slowTask)
+			So(err, ShouldBeNil)
+			So(mts[0].Data, ShouldEqual, 25)
+
+			src, err := p.getSource(slowTask[0].Config)
+			So(err, ShouldBeNil)
+			So(len(src.states), ShouldEqual, 2)
+		})
+
+		Convey("number of kept baselines should be bounded", func() {
+			for i := 0; i < maxStatesNumber+10; i++ {
+				_, err := p.CollectMetrics(newTaskMetricTypes(procPath, strconv.Itoa(i)))
+				So(err, ShouldBeNil)
+			}
+			src, err := p.getSource(slowTask[0].Config)
+			So(err, ShouldBeNil)
+			So(len(src.states), ShouldEqual, maxStatesNumber)
+			So(src.states, ShouldNotContainKey, getStateKey(newTaskMetricTypes(procPath, "0")))
+		})
+
+		Convey("tasks should be able to collect concurrently", func() {
+			var wg sync.WaitGroup
+			errs := make(chan error, concurrencyCount*10)
+			for i := 0; i < concurrencyCount; i++ {
+				wg.Add(1)
+				go func(taskID string) {
+					defer wg.Done()
+					for j := 0; j < 10; j++ {
+						_, err := p.CollectMetrics(newTaskMetricTypes(procPath, taskID))
+						errs <- err
+					}
+				}(strconv.Itoa(i % 2))
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				So(err, ShouldBeNil)
+			}
+		})
+	})
+}
+
+func TestCollectionIntervals(t *testing.T) {
+	Convey("Given sampling baseline", t, func() {
+		state := newSampleState()
+		start := time.Unix(1500000000, 0)
+		observe := func(offsets ...time.Duration) (reported int) {
+			for _, offset := range offsets {
+				if state.observeCollection(start.Add(offset)) {
+					reported++
+				}
+			}
+			return reported
+		}
+
+		Convey("collections at regular intervals should not be reported", func() {
+			So(observe(0, 10*time.Second, 20*time.Second, 31*time.Second, 40*time.Second, 60*time.Second), ShouldEqual, 0)
+		})
+
+		Convey("collections of tasks with different schedules should be reported once", func() {
+			// task collecting every 5s and another one collecting every 10s
+			offsets := []time.Duration{}
+			for i := 0; i < 10; i++ {
+				offsets = append(offsets, time.Duration(i)*10*time.Second, time.Duration(i)*10*time.Second+time.Second)
+				offsets = append(offsets, time.Duration(i)*10*time.Second+5*time.Second)
+			}
+			So(observe(offsets...), ShouldEqual, 1)
+		})
+	})
+}
+
+//clockFS in-memory filesystem whose files are read at time set by test
+type clockFS struct {
+	MapFS
+	now time.Time
+}
+
+//Now returns time set by test
+func (c *clockFS) Now() time.Time {
+	return c.now
+}
+
+func TestDeltasAndRates(t *testing.T) {
+	Convey("Given samples read 10 seconds apart", t, func() {
+		fixture := &clockFS{MapFS: MapFS{"proc/stat": firstProcStatSample}, now: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)}
+		p := NewWithFileSystem(fixture)
+		cfg := plugin.Config{"proc_path": "/proc"}
+		request := func(cpuID string, name string) plugin.Metric {
+			return plugin.Metric{Namespace: plugin.NewNamespace(vendor, fs, pluginName, cpuID, name), Config: cfg}
+		}
+		requests := []plugin.Metric{
+			request("0", "user_delta"), request("0", "user_rate"), request(allCPU, "active_delta"),
+			request(allCPU, "utilization_rate"), request("*", intervalMetricName),
+		}
+		mts, err := p.CollectMetrics(requests)
+		So(err, ShouldBeNil)
+		So(len(mts), ShouldEqual, 4)
+		for _, mt := range mts {
+			So(mt.Data, ShouldBeNil)
+		}
+		fixture.MapFS["proc/stat"] = secondProcStatSample
+		fixture.now = fixture.now.Add(10 * time.Second)
+
+		Convey("deltas and rates should be calculated over wall-clock interval", func() {
+			mts, err := p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 5)
+			So(mts[0].Data, ShouldEqual, uint64(50))
+			So(mts[0].Unit, ShouldEqual, jiffiesUnit)
+			So(mts[1].Data, ShouldEqual, 5.0)
+			So(mts[1].Unit, ShouldEqual, jiffiesPerSecondUnit)
+			So(mts[2].Data, ShouldEqual, uint64(100))
+			So(mts[3].Data, ShouldEqual, 10.0)
+			So(mts[4].Namespace[3].Value, ShouldEqual, allCPU)
+			So(mts[4].Data, ShouldEqual, 10.0)
+			So(mts[4].Unit, ShouldEqual, secondsUnit)
+		})
+
+		Convey("counters going back should not be reported as negative values", func() {
+			fixture.MapFS["proc/stat"] = "cpu  90 0 150 900 0 0 0 0 0 0\ncpu0 90 0 150 900 0 0 0 0 0 0"
+			mts, err := p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			So(mts[0].Data, ShouldBeNil)
+			So(mts[1].Data, ShouldBeNil)
+			So(mts[2].Data, ShouldNotBeNil)
+		})
+	})
+}
+
+//tickingFS filesystem with /proc/stat of host with given number of CPUs, user, system and idle counters
+//are incremented in place on every reading, so that benchmarks measure plugin rather than generating fixtures
+type tickingFS struct {
+	content  []byte
+	counters [][]byte
+}
+
+//newTickingFS creates filesystem with /proc/stat of host with given number of CPUs
+func newTickingFS(cpus int) *tickingFS {
+	buf := &bytes.Buffer{}
+	offsets := []int{}
+	line := func(id string) {
+		buf.WriteString(id)
+		for i := 0; i < 10; i++ {
+			buf.WriteString(" ")
+			if i == 0 || i == 2 || i == 3 {
+				offsets = append(offsets, buf.Len())
+			}
+			fmt.Fprintf(buf, "%020d", 1000*i)
+		}
+		buf.WriteString("\n")
+	}
+	line("cpu ")
+	for i := 0; i < cpus; i++ {
+		line("cpu" + strconv.Itoa(i))
+	}
+	buf.WriteString("intr 33594809 19 2 0 0 0 0 0 9 1 4 0 0 4 0 0 0 31 0 0\n")
+	t := &tickingFS{content: buf.Bytes()}
+	for _, offset := range offsets {
+		t.counters = append(t.counters, t.content[offset:offset+20])
+	}
+	return t
+}
+
+//Open returns /proc/stat with counters incremented since the previous reading
+func (t *tickingFS) Open(name string) (io.ReadCloser, error) {
+	for _, counter := range t.counters {
+		for i := len(counter) - 1; i >= 0; i-- {
+			if counter[i] != '9' {
+				counter[i]++
+				break
+			}
+			counter[i] = '0'
+		}
+	}
+	return ioutil.NopCloser(bytes.NewReader(t.content)), nil
+}
+
+func benchmarkCollect(b *testing.B, cpus int, cpuID string) {
+	p := NewWithFileSystem(newTickingFS(cpus))
+	cfg := plugin.Config{"proc_path": "/proc"}
+	mts := []plugin.Metric{}
+	for _, name := range []string{"user_jiffies", "user_percentage", "utilization_percentage"} {
+		mts = append(mts, plugin.Metric{Namespace: plugin.NewNamespace(vendor, fs, pluginName, cpuID, name), Config: cfg})
+	}
+	if _, err := p.CollectMetrics(mts); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.CollectMetrics(mts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCollect4CPUs(b *testing.B) {
+	benchmarkCollect(b, 4, "*")
+}
+
+func BenchmarkCollect64CPUs(b *testing.B) {
+	benchmarkCollect(b, 64, "*")
+}
+
+func BenchmarkCollect512CPUs(b *testing.B) {
+	benchmarkCollect(b, 512, "*")
+}
+
+func BenchmarkCollectAggregate512CPUs(b *testing.B) {
+	benchmarkCollect(b, 512, allCPU)
+}
+
+func TestHotplug(t *testing.T) {
+	Convey("Given plugin created while one CPU was online", t, func() {
+		fixture := MapFS{"proc/stat": firstProcStatSample}
+		p := NewWithFileSystem(fixture)
+		cfg := plugin.Config{"proc_path": "/proc"}
+		request := func(cpuID string, name string) plugin.Metric {
+			return plugin.Metric{Namespace: plugin.NewNamespace(vendor, fs, pluginName, cpuID, name), Config: cfg}
+		}
+		requests := []plugin.Metric{request("*", "user_jiffies"), request("*", "user_percentage")}
+		collect := func() ([]string, error) {
+			mts, err := p.CollectMetrics(requests)
+			names := []string{}
+			for _, mt := range mts {
+				names = append(names, mt.Namespace[len(mt.Namespace)-2].Value+"/"+mt.Namespace[len(mt.Namespace)-1].Value)
+			}
+			return names, err
+		}
+		_, err := collect()
+		So(err, ShouldBeNil)
+
+		Convey("CPU brought online later should be reported", func() {
+			fixture["proc/stat"] = secondProcStatSample + "\ncpu1 10 0 10 80 0 0 0 0 0 0"
+			names, err := collect()
+			So(err, ShouldBeNil)
+			So(names, ShouldResemble, []string{"all/user_jiffies", "0/user_jiffies", "1/user_jiffies", "all/user_percentage", "0/user_percentage"})
+
+			Convey("and its percentages should be reported from the next collection on", func() {
+				fixture["proc/stat"] = secondProcStatSample + "\ncpu1 20 0 10 90 0 0 0 0 0 0"
+				mts, err := p.CollectMetrics(requests)
+				So(err, ShouldBeNil)
+				So(mts[len(mts)-1].Namespace[len(mts[len(mts)-1].Namespace)-2].Value, ShouldEqual, "1")
+				So(mts[len(mts)-1].Data, ShouldEqual, 50)
+			})
+		})
+
+		Convey("collection should not fail when CPU goes offline", func() {
+			fixture["proc/stat"] = "cpu  150 0 150 900 0 0 0 0 0 0"
+			names, err := collect()
+			So(err, ShouldBeNil)
+			So(names, ShouldResemble, []string{"all/user_jiffies", "all/user_percentage"})
+		})
+	})
+}
@@ -0,0 +1,85 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+const (
+	//hostnameTag tag with name of host metric was collected from
+	hostnameTag = "hostname"
+
+	//kindTag tag of metric types telling whether metric is cumulative or gauge (see MetricKind)
+	kindTag = "kind"
+)
+
+//getMetricTags builds tags of metrics read from source for task with given config: static tags from config,
+//hostname and name of source if task collects from several procfs roots
+func (p *Plugin) getMetricTags(cfg plugin.Config, src *source, name string) (map[string]string, error) {
+	tags := map[string]string{}
+	if staticTags, err := cfg.GetString("tags"); err == nil {
+		_, values, err := parseNamedList(staticTags, "tags")
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range values {
+			tags[key] = value
+		}
+	}
+	tags[hostnameTag] = p.getHostname(cfg, src)
+	if name != "" {
+		tags[sourceTag] = name
+	}
+	return tags, nil
+}
+
+//getHostname returns hostname given in config, the one read from root directory of source
+//or hostname of machine plugin runs on, in this order
+func (p *Plugin) getHostname(cfg plugin.Config, src *source) string {
+	if hostname, err := cfg.GetString("hostname"); err == nil && hostname != "" {
+		return hostname
+	}
+	if src.hostname != "" {
+		return src.hostname
+	}
+	return p.host
+}
+
+//readRootHostname reads hostname from etc/hostname of root directory which contains proc directory of given stat file,
+//e.g. /hostfs/etc/hostname for /hostfs/proc/stat
+func readRootHostname(filesystem FileSystem, procPath string) (string, error) {
+	root := filepath.Dir(filepath.Dir(procPath))
+	content, err := readFile(filesystem, filepath.Join(root, "etc", "hostname"))
+	if err != nil {
+		return "", err
+	}
+	hostname := strings.TrimSpace(string(content))
+	if hostname == "" {
+		return "", fmt.Errorf("Empty %s", filepath.Join(root, "etc", "hostname"))
+	}
+	return hostname, nil
+}
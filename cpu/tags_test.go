@@ -0,0 +1,100 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMetricTags(t *testing.T) {
+	Convey("Given cpu plugin running in container with host root mounted at /hostfs", t, func() {
+		root, err := ioutil.TempDir("", "snap-plugin-collector-cpu")
+		So(err, ShouldBeNil)
+		Reset(func() { os.RemoveAll(root) })
+
+		hostProc := filepath.Join(root, "hostfs", "proc")
+		So(os.MkdirAll(hostProc, 0755), ShouldBeNil)
+		So(os.MkdirAll(filepath.Join(root, "hostfs", "etc"), 0755), ShouldBeNil)
+		So(ioutil.WriteFile(filepath.Join(root, "hostfs", "etc", "hostname"), []byte("node-17\n"), 0644), ShouldBeNil)
+		writeProcStat(hostProc, hostProcStatSample)
+
+		p := New()
+		p.host = "container-3f2a"
+		cfg := plugin.Config{"proc_path": hostProc}
+
+		collect := func() map[string]string {
+			mts, err := p.CollectMetrics([]plugin.Metric{
+				plugin.Metric{
+					Namespace: plugin.NewNamespace(vendor, fs, pluginName, "*", getNamespaceMetricPart(userProcStat, jiffiesRepresentationType)),
+					Config:    cfg,
+				},
+			})
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 2)
+			So(mts[0].Tags, ShouldResemble, mts[1].Tags)
+			return mts[0].Tags
+		}
+
+		Convey("hostname of machine plugin runs on should be attached by default", func() {
+			So(collect(), ShouldResemble, map[string]string{hostnameTag: "container-3f2a"})
+		})
+
+		Convey("hostname should be read from root directory containing proc_path when requested", func() {
+			cfg["hostname_from_root"] = true
+			So(collect(), ShouldResemble, map[string]string{hostnameTag: "node-17"})
+		})
+
+		Convey("hostname given in config should take precedence", func() {
+			cfg["hostname_from_root"] = true
+			cfg["hostname"] = "web-01"
+			So(collect(), ShouldResemble, map[string]string{hostnameTag: "web-01"})
+		})
+
+		Convey("static tags from config should be attached", func() {
+			cfg["tags"] = "rack=r12, env=prod"
+			So(collect(), ShouldResemble, map[string]string{hostnameTag: "container-3f2a", "rack": "r12", "env": "prod"})
+		})
+
+		Convey("incorrect static tags should be reported", func() {
+			cfg["tags"] = "rack"
+			_, err := p.CollectMetrics([]plugin.Metric{
+				plugin.Metric{
+					Namespace: plugin.NewNamespace(vendor, fs, pluginName, "*", getNamespaceMetricPart(userProcStat, jiffiesRepresentationType)),
+					Config:    cfg,
+				},
+			})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("missing hostname file should fall back to hostname of machine plugin runs on", func() {
+			So(os.Remove(filepath.Join(root, "hostfs", "etc", "hostname")), ShouldBeNil)
+			cfg["hostname_from_root"] = true
+			So(collect(), ShouldResemble, map[string]string{hostnameTag: "container-3f2a"})
+		})
+	})
+}
@@ -0,0 +1,85 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	//minWarmup shortest warm-up sample, jiffies are counted at 100 Hz, so that percentages of CPU
+	//over shorter sample would be calculated from a few jiffies and they would be too coarse to be useful
+	minWarmup = 100 * time.Millisecond
+
+	//maxWarmup longest warm-up sample, collection is blocked for its whole length
+	maxWarmup = 5 * time.Second
+)
+
+//parseWarmup parses length of warm-up sample given in warmup config item, empty or zero one disables warm-up
+func parseWarmup(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	warmup, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("Incorrect warmup {%s}: %v", value, err)
+	}
+	if warmup != 0 && (warmup < minWarmup || warmup > maxWarmup) {
+		return 0, fmt.Errorf("Incorrect warmup {%s}, expected between %v and %v", value, minWarmup, maxWarmup)
+	}
+	return warmup, nil
+}
+
+//warmUp takes warm-up sample when current sample has no baseline, i.e. it is the first one of the task
+//or CPU which was not present in the previous sample is requested; it waits for length of warm-up sample
+//and reads source again, so that values are calculated over that window, its real length is reported
+//by interval_seconds.
+//Files of filesystems which tell time they were read at (e.g. replayed sessions) don't change
+//while waiting, so warm-up sample is never taken from them
+func (s *source) warmUp(state *sampleState, plan *collectionPlan) error {
+	if s.warmup == 0 || !plan.paired || !state.needsBaseline(plan.perCPU) {
+		return nil
+	}
+	if _, ok := s.filesystem.(Clock); ok {
+		return nil
+	}
+	time.Sleep(s.warmup)
+	return state.read(s.filesystem, s.procPath, len(s.procStatMetricsNames), plan.perCPU)
+}
+
+//needsBaseline tells whether some values of current sample cannot be calculated for lack of the previous one,
+//CPUs are checked only when lines of individual CPUs are read
+func (st *sampleState) needsBaseline(perCPU bool) bool {
+	if st.samples < 2 {
+		return true
+	}
+	if !perCPU {
+		return false
+	}
+	for i, cpu := range st.curr.CPUs {
+		if st.prevCPU(i, cpu.ID) == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//sequenceFS in-memory filesystem whose /proc/stat has the next of given contents every time it is opened,
+//the last one is repeated
+type sequenceFS struct {
+	mutex    sync.Mutex
+	contents []string
+	opened   int
+}
+
+//Open opens the next contents of /proc/stat
+func (s *sequenceFS) Open(name string) (io.ReadCloser, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	content := s.contents[len(s.contents)-1]
+	if s.opened < len(s.contents) {
+		content = s.contents[s.opened]
+	}
+	s.opened++
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func TestWarmup(t *testing.T) {
+	Convey("Given plugin taking warm-up sample", t, func() {
+		fixture := &sequenceFS{contents: []string{firstProcStatSample, firstProcStatSample, secondProcStatSample}}
+		p := NewWithFileSystem(fixture)
+		cfg := plugin.Config{"proc_path": "/proc", "warmup": "100ms"}
+		request := func(cpuID string, name string) plugin.Metric {
+			return plugin.Metric{Namespace: plugin.NewNamespace(vendor, fs, pluginName, cpuID, name), Config: cfg}
+		}
+
+		Convey("percentages should be returned by the first collection together with real window as interval", func() {
+			requests := []plugin.Metric{request("0", "user_percentage"), request("0", "user_jiffies"), request("*", intervalMetricName)}
+			mts, err := p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 3)
+			So(fixture.opened, ShouldEqual, 3)
+			So(mts[0].Data, ShouldEqual, 25)
+			So(mts[1].Data, ShouldEqual, uint64(150))
+			So(mts[2].Data, ShouldBeGreaterThanOrEqualTo, 0.1)
+			So(mts[2].Data, ShouldBeLessThan, 5)
+
+			Convey("warm-up sample should not be taken when there is baseline", func() {
+				_, err := p.CollectMetrics(requests)
+				So(err, ShouldBeNil)
+				So(fixture.opened, ShouldEqual, 4)
+			})
+		})
+
+		Convey("warm-up sample should be taken when CPU is brought online", func() {
+			fixture.contents = append(fixture.contents,
+				secondProcStatSample+"\ncpu1 10 0 10 80 0 0 0 0 0 0",
+				"cpu  160 0 160 920 0 0 0 0 0 0\ncpu0 150 0 150 900 0 0 0 0 0 0\ncpu1 20 0 10 90 0 0 0 0 0 0")
+			requests := []plugin.Metric{request("*", "user_percentage")}
+			_, err := p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			So(fixture.opened, ShouldEqual, 3)
+			mts, err := p.CollectMetrics(requests)
+			So(err, ShouldBeNil)
+			So(fixture.opened, ShouldEqual, 5)
+			So(len(mts), ShouldEqual, 2)
+			So(mts[1].Namespace[len(mts[1].Namespace)-2].Value, ShouldEqual, "1")
+			So(mts[1].Data, ShouldEqual, 50)
+		})
+
+		Convey("warm-up sample should not be taken when no value needs baseline", func() {
+			mts, err := p.CollectMetrics([]plugin.Metric{request("0", "user_jiffies")})
+			So(err, ShouldBeNil)
+			So(len(mts), ShouldEqual, 1)
+			So(fixture.opened, ShouldEqual, 2)
+		})
+
+		Convey("warm-up sample should not be taken from replayed files", func() {
+			replayed := &clockFS{MapFS: MapFS{"proc/stat": firstProcStatSample}, now: time.Now()}
+			mts, err := NewWithFileSystem(replayed).CollectMetrics([]plugin.Metric{request("0", "user_percentage")})
+			So(err, ShouldBeNil)
+			So(mts[0].Data, ShouldBeNil)
+		})
+
+		Convey("incorrect warm-up length should be reported", func() {
+			for _, warmup := range []string{"10ms", "99ms", "1m", "soon"} {
+				cfg["warmup"] = warmup
+				_, err := p.CollectMetrics([]plugin.Metric{request("0", "user_percentage")})
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
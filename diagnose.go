@@ -0,0 +1,229 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cpu/cpu"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+const (
+	//tableFormat output of diagnose mode as aligned table
+	tableFormat = "table"
+
+	//jsonFormat output of diagnose mode as JSON object per iteration
+	jsonFormat = "json"
+)
+
+//collectorFlags command-line flags which are passed to collector as config items of task
+type collectorFlags struct {
+	procPath             *string
+	procPaths            *string
+	reportUnknownColumns *bool
+	hostname             *string
+	hostnameFromRoot     *bool
+	tags                 *string
+	samplingInterval     *string
+	rollingWindows       *string
+	stateDir             *string
+	warmup               *string
+	cpuList              *string
+	aggregatesOnly       *bool
+	topCPUs              *int
+}
+
+//newCollectorFlags defines flags corresponding to config items of collector
+func newCollectorFlags(flags *flag.FlagSet) *collectorFlags {
+	return &collectorFlags{
+		procPath:             flags.String("proc_path", "/proc", "path to proc directory"),
+		procPaths:            flags.String("proc_paths", "", "list of named proc directories, e.g. host=/proc,sidecar=/hostproc"),
+		reportUnknownColumns: flags.Bool("report_unknown_columns", true, "report /proc/stat columns not known to plugin as column<N> metrics"),
+		hostname:             flags.String("hostname", "", "hostname attached to metrics"),
+		hostnameFromRoot:     flags.Bool("hostname_from_root", false, "read hostname from etc/hostname next to proc directory"),
+		tags:                 flags.String("tags", "", "static tags attached to metrics, e.g. rack=r12,env=prod"),
+		samplingInterval:     flags.String("sampling_interval", "", "sub-interval of background sampler, e.g. 1s, sampler is disabled when not given"),
+		rollingWindows:       flags.String("rolling_windows", "", "windows of rolling averages of percentages, e.g. 1m,5m,15m"),
+		stateDir:             flags.String("state_dir", "", "directory where baselines are persisted across restarts"),
+		warmup:               flags.String("warmup", "", "length of warm-up sample taken when there is no baseline, e.g. 250ms"),
+		cpuList:              flags.String("cpu_list", "", "CPUs whose metrics are reported, e.g. 0-3,8,16-31, all CPUs when not given"),
+		aggregatesOnly:       flags.Bool("aggregates_only", false, "report aggregates of all CPUs only"),
+		topCPUs:              flags.Int("top_cpus", 0, "report metrics of the given number of the busiest CPUs only"),
+	}
+}
+
+//config returns config of task built from flags, empty flags are not passed
+func (f *collectorFlags) config() plugin.Config {
+	cfg := plugin.Config{
+		"proc_path":              *f.procPath,
+		"report_unknown_columns": *f.reportUnknownColumns,
+		"hostname_from_root":     *f.hostnameFromRoot,
+		"aggregates_only":        *f.aggregatesOnly,
+	}
+	if *f.topCPUs != 0 {
+		cfg["top_cpus"] = int64(*f.topCPUs)
+	}
+	for key, value := range map[string]string{"proc_paths": *f.procPaths, "hostname": *f.hostname, "tags": *f.tags, "sampling_interval": *f.samplingInterval,
+		"rolling_windows": *f.rollingWindows, "state_dir": *f.stateDir, "warmup": *f.warmup, "cpu_list": *f.cpuList} {
+		if value != "" {
+			cfg[key] = value
+		}
+	}
+	return cfg
+}
+
+//requestAllMetrics returns all metric types available for given config, ready to be passed to CollectMetrics;
+//they are requested like Snap tasks request them, with config of task and without tags of metric catalog
+func requestAllMetrics(p *cpu.Plugin, cfg plugin.Config) ([]plugin.Metric, error) {
+	mts, err := p.GetMetricTypes(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for i := range mts {
+		mts[i].Config = cfg
+		mts[i].Tags = nil
+	}
+	return mts, nil
+}
+
+//diagnosedMetric metric printed in diagnose mode
+type diagnosedMetric struct {
+	Namespace string            `json:"namespace"`
+	Value     interface{}       `json:"value"`
+	Unit      string            `json:"unit"`
+	Tags      map[string]string `json:"tags"`
+}
+
+//diagnosedIteration metrics collected in single iteration of diagnose mode
+type diagnosedIteration struct {
+	Iteration int               `json:"iteration"`
+	Timestamp time.Time         `json:"timestamp"`
+	Metrics   []diagnosedMetric `json:"metrics"`
+}
+
+//diagnose runs collector without snapteld and prints metrics collected in given number of iterations,
+//percentages are available from the second iteration on
+func diagnose(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("diagnose", flag.ContinueOnError)
+	collector := newCollectorFlags(flags)
+	iterations := flags.Int("iterations", 2, "number of collections")
+	interval := flags.Duration("interval", time.Second, "interval between collections")
+	format := flags.String("format", tableFormat, "output format, "+tableFormat+" or "+jsonFormat)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *format != tableFormat && *format != jsonFormat {
+		return fmt.Errorf("Unknown format {%s}, expected %s or %s", *format, tableFormat, jsonFormat)
+	}
+	if *iterations < 1 {
+		return fmt.Errorf("Incorrect number of iterations {%d}", *iterations)
+	}
+
+	p := cpu.New()
+	defer p.Close()
+	mts, err := requestAllMetrics(p, collector.config())
+	if err != nil {
+		return err
+	}
+	for i := 1; i <= *iterations; i++ {
+		if i > 1 {
+			time.Sleep(*interval)
+		}
+		metrics, err := p.CollectMetrics(mts)
+		if err != nil {
+			return err
+		}
+		iteration := diagnosedIteration{Iteration: i, Timestamp: time.Now(), Metrics: getDiagnosedMetrics(metrics)}
+		if err := writeDiagnosedIteration(out, *format, iteration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//getDiagnosedMetrics converts collected metrics to printed ones, sorted by namespace
+func getDiagnosedMetrics(metrics []plugin.Metric) []diagnosedMetric {
+	diagnosed := make([]diagnosedMetric, len(metrics))
+	for i, metric := range metrics {
+		diagnosed[i] = diagnosedMetric{
+			Namespace: metric.Namespace.String(),
+			Value:     metric.Data,
+			Unit:      metric.Unit,
+			Tags:      metric.Tags,
+		}
+	}
+	sort.Sort(byNamespace(diagnosed))
+	return diagnosed
+}
+
+//byNamespace sorts diagnosed metrics by namespace and then by tags
+type byNamespace []diagnosedMetric
+
+func (b byNamespace) Len() int      { return len(b) }
+func (b byNamespace) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byNamespace) Less(i, j int) bool {
+	if b[i].Namespace != b[j].Namespace {
+		return b[i].Namespace < b[j].Namespace
+	}
+	return formatTags(b[i].Tags) < formatTags(b[j].Tags)
+}
+
+//writeDiagnosedIteration writes metrics collected in single iteration in given format
+func writeDiagnosedIteration(out io.Writer, format string, iteration diagnosedIteration) error {
+	if format == jsonFormat {
+		return json.NewEncoder(out).Encode(iteration)
+	}
+	return writeDiagnosedTable(out, iteration)
+}
+
+//writeDiagnosedTable writes metrics collected in single iteration as aligned table
+func writeDiagnosedTable(out io.Writer, iteration diagnosedIteration) error {
+	fmt.Fprintf(out, "# iteration %d at %s\n", iteration.Iteration, iteration.Timestamp.Format(time.RFC3339))
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tVALUE\tUNIT\tTAGS")
+	for _, metric := range iteration.Metrics {
+		fmt.Fprintf(w, "%s\t%v\t%s\t%s\n", metric.Namespace, metric.Value, metric.Unit, formatTags(metric.Tags))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(out)
+	return err
+}
+
+//formatTags returns tags as name=value pairs sorted by name and separated by commas
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
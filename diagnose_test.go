@@ -0,0 +1,92 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const procStatSample = `cpu  100 0 100 800 0 0 0 0 0 0
+cpu0 100 0 100 800 0 0 0 0 0 0`
+
+//newProcPath creates temporary proc directory with mocked stat file
+func newProcPath(content string) string {
+	procPath, err := ioutil.TempDir("", "snap-plugin-collector-cpu")
+	if err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(procPath, "stat"), []byte(content), 0644); err != nil {
+		panic(err)
+	}
+	return procPath
+}
+
+func TestDiagnose(t *testing.T) {
+	Convey("Given proc directory", t, func() {
+		procPath := newProcPath(procStatSample)
+		Reset(func() { os.RemoveAll(procPath) })
+		out := &bytes.Buffer{}
+
+		Convey("metrics should be printed as table", func() {
+			err := diagnose([]string{"-proc_path", procPath, "-iterations", "2", "-interval", "1ms", "-hostname", "node-17"}, out)
+			So(err, ShouldBeNil)
+			So(strings.Count(out.String(), "# iteration"), ShouldEqual, 2)
+			So(out.String(), ShouldContainSubstring, "NAMESPACE")
+			lines := strings.Split(out.String(), "\n")
+			found := false
+			for _, line := range lines {
+				fields := strings.Fields(line)
+				if len(fields) == 4 && fields[0] == "/intel/procfs/cpu/all/user_jiffies" {
+					So(fields[1:], ShouldResemble, []string{"100", "jiffies", "hostname=node-17"})
+					found = true
+				}
+			}
+			So(found, ShouldBeTrue)
+		})
+
+		Convey("metrics should be printed as JSON", func() {
+			err := diagnose([]string{"-proc_path", procPath, "-iterations", "1", "-format", "json", "-tags", "rack=r12"}, out)
+			So(err, ShouldBeNil)
+			iteration := diagnosedIteration{}
+			So(json.Unmarshal(out.Bytes(), &iteration), ShouldBeNil)
+			So(iteration.Iteration, ShouldEqual, 1)
+			// percentages are not available in the first iteration
+			So(len(iteration.Metrics), ShouldEqual, 24)
+			So(iteration.Metrics[0].Tags["rack"], ShouldEqual, "r12")
+			So(iteration.Metrics[0].Unit, ShouldNotBeEmpty)
+		})
+
+		Convey("incorrect arguments should be reported", func() {
+			So(diagnose([]string{"-proc_path", procPath, "-format", "xml"}, out), ShouldNotBeNil)
+			So(diagnose([]string{"-proc_path", procPath, "-iterations", "0"}, out), ShouldNotBeNil)
+			So(diagnose([]string{"-proc_path", filepath.Join(procPath, "missing")}, out), ShouldNotBeNil)
+		})
+	})
+}
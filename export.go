@@ -0,0 +1,266 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cpu/cpu"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+const (
+	//influxFormat output of export mode as InfluxDB line protocol
+	influxFormat = "influx"
+
+	//jsonLinesFormat output of export mode as JSON object per metric and line
+	jsonLinesFormat = "jsonl"
+)
+
+var (
+	//influxMeasurementEscaper escapes special characters of InfluxDB measurement names
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+
+	//influxTagEscaper escapes special characters of InfluxDB tag keys, tag values and field keys
+	influxTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+//exportedMetric metric written as JSON line
+type exportedMetric struct {
+	Namespace      string            `json:"namespace"`
+	CPU            string            `json:"cpu"`
+	State          string            `json:"state"`
+	Representation string            `json:"representation"`
+	Value          interface{}       `json:"value"`
+	Unit           string            `json:"unit"`
+	Kind           cpu.MetricKind    `json:"kind"`
+	Tags           map[string]string `json:"tags"`
+	Timestamp      time.Time         `json:"timestamp"`
+}
+
+//influxPoint metrics of single CPU with the same tags written as single line
+type influxPoint struct {
+	measurement string
+	tags        map[string]string
+	fields      map[string]string
+	timestamp   time.Time
+}
+
+//openOutput opens output given as "-" for stdout, udp://host:port, tcp://host:port or path to file which is appended to
+func openOutput(output string) (io.WriteCloser, error) {
+	switch {
+	case output == "" || output == "-":
+		return nopCloser{os.Stdout}, nil
+	case strings.HasPrefix(output, "udp://"):
+		return net.Dial("udp", strings.TrimPrefix(output, "udp://"))
+	case strings.HasPrefix(output, "tcp://"):
+		return net.Dial("tcp", strings.TrimPrefix(output, "tcp://"))
+	}
+	return os.OpenFile(strings.TrimPrefix(output, "file://"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+}
+
+//nopCloser writer which is not closed when output is done, e.g. stdout
+type nopCloser struct {
+	io.Writer
+}
+
+//Close does nothing
+func (nopCloser) Close() error {
+	return nil
+}
+
+//export collects metrics the given number of times (forever if not given) and writes them to output,
+//each line is written separately to fit into single datagram when sent over UDP
+func export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	collector := newCollectorFlags(flags)
+	format := flags.String("format", influxFormat, "output format, "+influxFormat+" or "+jsonLinesFormat)
+	output := flags.String("output", "-", "output, - for stdout, udp://host:port, tcp://host:port or path to file")
+	iterations := flags.Int("iterations", 0, "number of collections, metrics are collected until failure when not given")
+	interval := flags.Duration("interval", 10*time.Second, "interval between collections")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	var formatter func([]plugin.Metric) ([][]byte, error)
+	switch *format {
+	case influxFormat:
+		formatter = formatInflux
+	case jsonLinesFormat:
+		formatter = formatJSONLines
+	default:
+		return fmt.Errorf("Unknown format {%s}, expected %s or %s", *format, influxFormat, jsonLinesFormat)
+	}
+
+	p := cpu.New()
+	defer p.Close()
+	mts, err := requestAllMetrics(p, collector.config())
+	if err != nil {
+		return err
+	}
+	out, err := openOutput(*output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	for i := 1; *iterations <= 0 || i <= *iterations; i++ {
+		if i > 1 {
+			time.Sleep(*interval)
+		}
+		metrics, err := p.CollectMetrics(mts)
+		if err != nil {
+			return err
+		}
+		lines, err := formatter(metrics)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if _, err := out.Write(line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//formatJSONLines formats each metric as JSON object terminated by newline
+func formatJSONLines(metrics []plugin.Metric) ([][]byte, error) {
+	lines := make([][]byte, 0, len(metrics))
+	for _, metric := range metrics {
+		ns := metric.Namespace.Strings()
+		descriptor, ok := cpu.DescribeMetric(ns[len(ns)-1])
+		if !ok {
+			return nil, fmt.Errorf("Unknown metric {%s}", metric.Namespace.String())
+		}
+		line := &bytes.Buffer{}
+		err := json.NewEncoder(line).Encode(exportedMetric{
+			Namespace:      metric.Namespace.String(),
+			CPU:            ns[len(ns)-2],
+			State:          descriptor.State,
+			Representation: descriptor.Representation,
+			Value:          metric.Data,
+			Unit:           metric.Unit,
+			Kind:           descriptor.Kind,
+			Tags:           metric.Tags,
+			Timestamp:      metric.Timestamp,
+		})
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line.Bytes())
+	}
+	return lines, nil
+}
+
+//formatInflux formats metrics as InfluxDB line protocol, metrics of each CPU with the same tags form single point,
+//e.g. intel_procfs_cpu,cpu=3,hostname=node-17 user_jiffies=100i,user_percentage=25 1479398400000000000
+func formatInflux(metrics []plugin.Metric) ([][]byte, error) {
+	points := map[string]*influxPoint{}
+	for _, metric := range metrics {
+		ns := metric.Namespace.Strings()
+		value, ok := formatInfluxValue(metric.Data)
+		if !ok {
+			return nil, fmt.Errorf("Unsupported value {%v} of metric {%s}", metric.Data, metric.Namespace.String())
+		}
+		tags := map[string]string{cpuLabel: ns[len(ns)-2]}
+		for key, value := range metric.Tags {
+			tags[key] = value
+		}
+		measurement := strings.Join(ns[:len(ns)-2], "_")
+		key := measurement + " " + formatTags(tags)
+		point, ok := points[key]
+		if !ok {
+			point = &influxPoint{measurement: measurement, tags: tags, fields: map[string]string{}, timestamp: metric.Timestamp}
+			points[key] = point
+		}
+		point.fields[ns[len(ns)-1]] = value
+	}
+
+	keys := make([]string, 0, len(points))
+	for key := range points {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lines := make([][]byte, len(keys))
+	for i, key := range keys {
+		lines[i] = points[key].format()
+	}
+	return lines, nil
+}
+
+//formatInfluxValue formats metric value as field value of line protocol, counters are written as integers
+//(clamped to int64 like in Telegraf, as unsigned integers are not supported by all InfluxDB versions)
+func formatInfluxValue(data interface{}) (string, bool) {
+	if value, ok := data.(uint64); ok {
+		if value > math.MaxInt64 {
+			value = math.MaxInt64
+		}
+		return strconv.FormatUint(value, 10) + "i", true
+	}
+	value, ok := toFloat(data)
+	if !ok {
+		return "", false
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64), true
+}
+
+//format returns point as line of InfluxDB line protocol with tags and fields sorted by name
+func (p *influxPoint) format() []byte {
+	line := &bytes.Buffer{}
+	line.WriteString(influxMeasurementEscaper.Replace(p.measurement))
+	tagKeys := make([]string, 0, len(p.tags))
+	for key := range p.tags {
+		tagKeys = append(tagKeys, key)
+	}
+	sort.Strings(tagKeys)
+	for _, key := range tagKeys {
+		if p.tags[key] == "" {
+			continue // empty tag values are not allowed by line protocol
+		}
+		fmt.Fprintf(line, ",%s=%s", influxTagEscaper.Replace(key), influxTagEscaper.Replace(p.tags[key]))
+	}
+	fieldKeys := make([]string, 0, len(p.fields))
+	for key := range p.fields {
+		fieldKeys = append(fieldKeys, key)
+	}
+	sort.Strings(fieldKeys)
+	for i, key := range fieldKeys {
+		separator := ","
+		if i == 0 {
+			separator = " "
+		}
+		fmt.Fprintf(line, "%s%s=%s", separator, influxTagEscaper.Replace(key), p.fields[key])
+	}
+	fmt.Fprintf(line, " %d\n", p.timestamp.UnixNano())
+	return line.Bytes()
+}
@@ -0,0 +1,120 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExport(t *testing.T) {
+	Convey("Given proc directory", t, func() {
+		procPath := newProcPath(procStatSample)
+		Reset(func() { os.RemoveAll(procPath) })
+		args := []string{"-proc_path", procPath, "-hostname", "node 17", "-iterations", "1"}
+
+		Convey("metrics should be sent in line protocol over TCP", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			So(err, ShouldBeNil)
+			defer listener.Close()
+			received := make(chan []string, 1)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					received <- nil
+					return
+				}
+				defer conn.Close()
+				lines := []string{}
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines = append(lines, scanner.Text())
+				}
+				received <- lines
+			}()
+
+			So(export(append(args, "-output", "tcp://"+listener.Addr().String())), ShouldBeNil)
+			lines := <-received
+			So(len(lines), ShouldEqual, 2)
+			So(lines[1], ShouldStartWith, `intel_procfs_cpu,cpu=all,hostname=node\ 17 active_jiffies=200i,`)
+			So(lines[1], ShouldContainSubstring, ",user_jiffies=100i,")
+		})
+
+		Convey("metrics should be sent as JSON lines over UDP", func() {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			So(err, ShouldBeNil)
+			defer conn.Close()
+
+			So(export(append(args, "-format", "jsonl", "-output", "udp://"+conn.LocalAddr().String())), ShouldBeNil)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			buffer := make([]byte, 65536)
+			n, _, err := conn.ReadFrom(buffer)
+			So(err, ShouldBeNil)
+			metric := exportedMetric{}
+			So(json.Unmarshal(buffer[:n], &metric), ShouldBeNil)
+			So(metric.Namespace, ShouldStartWith, "/intel/procfs/cpu/")
+			So(metric.Kind, ShouldNotBeEmpty)
+			So(metric.Tags["hostname"], ShouldEqual, "node 17")
+		})
+
+		Convey("metrics should be appended to file", func() {
+			path := filepath.Join(procPath, "metrics.jsonl")
+			So(export(append(args, "-format", "jsonl", "-output", path)), ShouldBeNil)
+			So(export(append(args, "-format", "jsonl", "-output", "file://"+path)), ShouldBeNil)
+			content, err := ioutil.ReadFile(path)
+			So(err, ShouldBeNil)
+			// 24 jiffies metrics in each collection, percentages are not available in the first one
+			So(strings.Count(string(content), "\n"), ShouldEqual, 48)
+		})
+
+		Convey("unknown format should be reported", func() {
+			So(export(append(args, "-format", "csv")), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given metrics of CPU", t, func() {
+		ts := time.Unix(1479398400, 0)
+		metrics := []plugin.Metric{
+			plugin.Metric{Namespace: plugin.NewNamespace("intel", "procfs", "cpu", "3", "user_jiffies"), Data: uint64(100), Tags: map[string]string{"rack": "r,12"}, Timestamp: ts},
+			plugin.Metric{Namespace: plugin.NewNamespace("intel", "procfs", "cpu", "3", "user_percentage"), Data: float64(25.5), Tags: map[string]string{"rack": "r,12"}, Timestamp: ts},
+			plugin.Metric{Namespace: plugin.NewNamespace("intel", "procfs", "cpu", "all", "user_jiffies"), Data: uint64(9007199254740993), Timestamp: ts},
+		}
+
+		Convey("they should be grouped into points of line protocol", func() {
+			lines, err := formatInflux(metrics)
+			So(err, ShouldBeNil)
+			So(len(lines), ShouldEqual, 2)
+			So(string(lines[0]), ShouldEqual, `intel_procfs_cpu,cpu=3,rack=r\,12 user_jiffies=100i,user_percentage=25.5 1479398400000000000`+"\n")
+			So(string(lines[1]), ShouldEqual, "intel_procfs_cpu,cpu=all user_jiffies=9007199254740993i 1479398400000000000\n")
+		})
+	})
+}
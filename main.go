@@ -22,12 +22,36 @@ limitations under the License.
 package main
 
 import (
+	"flag"
+	"fmt"
 	"os"
 
 	"github.com/intelsdi-x/snap-plugin-collector-cpu/cpu"
-	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
 )
 
+//modes modes in which binary can be run instead of serving snapteld, selected by the first argument
+var modes = map[string]func(args []string) error{
+	"analyze":    func(args []string) error { return analyze(args, os.Stdout) },
+	"diagnose":   func(args []string) error { return diagnose(args, os.Stdout) },
+	"export":     export,
+	"prometheus": servePrometheus,
+	"record":     record,
+	"replay":     func(args []string) error { return replay(args, os.Stdout) },
+	"textfile":   writeTextfile,
+}
+
 func main() {
-	plugin.Start(cpu.Meta(), cpu.New(), os.Args[1])
+	if len(os.Args) > 1 {
+		if mode, ok := modes[os.Args[1]]; ok {
+			if err := mode(os.Args[2:]); err != nil && err != flag.ErrHelp {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	p := cpu.New()
+	plugin.StartCollector(p, cpu.PluginName, cpu.PluginVersion, cpu.Meta()...)
+	p.Close()
 }
@@ -0,0 +1,59 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procstat
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+//btimePrefix prefix of /proc/stat line with boot time
+var btimePrefix = []byte("btime ")
+
+//BootTime reads time at which the system booted, in seconds since the Epoch, from btime line of /proc/stat,
+//it identifies boot of the system, so that samples taken before reboot are not compared with the current ones
+func BootTime(r io.Reader) (uint64, error) {
+	reader := bufio.NewReader(r)
+	lineStart := true
+	for {
+		line, err := reader.ReadSlice('\n')
+		//lines longer than buffer (e.g. intr on hosts with many interrupts) are read in parts
+		if lineStart && bytes.HasPrefix(line, btimePrefix) {
+			value := bytes.TrimSpace(line[len(btimePrefix):])
+			btime, ok := parseUint(value)
+			if !ok {
+				return 0, fmt.Errorf("Cannot parse btime: invalid value %q", value)
+			}
+			return btime, nil
+		}
+		switch err {
+		case nil:
+			lineStart = true
+		case bufio.ErrBufferFull:
+			lineStart = false
+		case io.EOF:
+			return 0, fmt.Errorf("No btime line found")
+		default:
+			return 0, err
+		}
+	}
+}
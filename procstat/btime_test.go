@@ -0,0 +1,51 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procstat
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBootTime(t *testing.T) {
+	Convey("Given /proc/stat", t, func() {
+		Convey("boot time should be read from btime line", func() {
+			btime, err := BootTime(strings.NewReader(firstSample + "\nintr 12 0 1\nctxt 100\nbtime 1479398400\nprocesses 7\n"))
+			So(err, ShouldBeNil)
+			So(btime, ShouldEqual, uint64(1479398400))
+		})
+
+		Convey("boot time should be found after lines longer than buffer", func() {
+			intr := "intr" + strings.Repeat(" 0", 10000)
+			btime, err := BootTime(strings.NewReader(firstSample + "\n" + intr + " btime 1\nbtime 1479398400"))
+			So(err, ShouldBeNil)
+			So(btime, ShouldEqual, uint64(1479398400))
+		})
+
+		Convey("missing or invalid btime line should be reported", func() {
+			_, err := BootTime(strings.NewReader(firstSample))
+			So(err, ShouldNotBeNil)
+			_, err = BootTime(strings.NewReader("btime yesterday\n"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
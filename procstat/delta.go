@@ -0,0 +1,135 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procstat
+
+import "fmt"
+
+//Delta time (in jiffies) spent by CPU in each state between two samples,
+//a counter which went back between samples (e.g. iowait on tickless kernels) has negative value
+type Delta struct {
+	User      int64
+	Nice      int64
+	System    int64
+	Idle      int64
+	Iowait    int64
+	IRQ       int64
+	SoftIRQ   int64
+	Steal     int64
+	Guest     int64
+	GuestNice int64
+	//Extra columns reported after guest_nice by kernels newer than this package
+	Extra []int64
+}
+
+//CPUDelta time spent by single CPU in each state between two samples
+type CPUDelta struct {
+	ID    int
+	Delta Delta
+}
+
+//SampleDelta time spent by CPUs in each state between two samples
+type SampleDelta struct {
+	//All time spent by all CPUs together
+	All Delta
+	//CPUs time spent by each CPU present in both samples
+	CPUs []CPUDelta
+}
+
+//Delta returns time spent by CPUs in each state since prev was read
+func (s *Sample) Delta(prev *Sample) (*SampleDelta, error) {
+	if s.Columns != prev.Columns {
+		return nil, fmt.Errorf("Number of CPU columns changed from {%d} to {%d}", prev.Columns, s.Columns)
+	}
+	prevCPUs := make(map[int]CPUTimes, len(prev.CPUs))
+	for _, cpu := range prev.CPUs {
+		prevCPUs[cpu.ID] = cpu.Times
+	}
+	delta := &SampleDelta{All: s.All.Delta(prev.All)}
+	for _, cpu := range s.CPUs {
+		if prevTimes, ok := prevCPUs[cpu.ID]; ok {
+			delta.CPUs = append(delta.CPUs, CPUDelta{ID: cpu.ID, Delta: cpu.Times.Delta(prevTimes)})
+		}
+	}
+	return delta, nil
+}
+
+//column returns pointer to value of column with given position
+func (d *Delta) column(i int) *int64 {
+	switch i {
+	case 0:
+		return &d.User
+	case 1:
+		return &d.Nice
+	case 2:
+		return &d.System
+	case 3:
+		return &d.Idle
+	case 4:
+		return &d.Iowait
+	case 5:
+		return &d.IRQ
+	case 6:
+		return &d.SoftIRQ
+	case 7:
+		return &d.Steal
+	case 8:
+		return &d.Guest
+	case 9:
+		return &d.GuestNice
+	}
+	return &d.Extra[i-knownColumns]
+}
+
+//Column returns value of column with given position, positions follow ColumnNames and then Extra columns
+func (d Delta) Column(i int) int64 {
+	if i >= knownColumns+len(d.Extra) {
+		return 0
+	}
+	return *d.column(i)
+}
+
+//Total returns time elapsed between samples, i.e. sum of all columns
+func (d Delta) Total() int64 {
+	total := d.User + d.Nice + d.System + d.Idle + d.Iowait + d.IRQ + d.SoftIRQ + d.Steal + d.Guest + d.GuestNice
+	for _, value := range d.Extra {
+		total += value
+	}
+	return total
+}
+
+//Active returns time spent in all states except idle
+func (d Delta) Active() int64 {
+	return d.Total() - d.Idle
+}
+
+//Utilization returns time spent in all states except idle and iowait
+func (d Delta) Utilization() int64 {
+	return d.Active() - d.Iowait
+}
+
+//Percent returns given time (e.g. d.User or d.Active()) as percent of time elapsed between samples,
+//ok is false when it cannot be calculated because no time elapsed or counters went back
+func (d Delta) Percent(value int64) (percent float64, ok bool) {
+	total := d.Total()
+	if total <= 0 || value < 0 {
+		return 0, false
+	}
+	return 100 * float64(value) / float64(total), true
+}
@@ -0,0 +1,203 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procstat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	//initialBufferSize size of buffer of parser before it is grown to fit the longest CPU line
+	initialBufferSize = 4096
+
+	//maxSafeDigits number of decimal digits which always fit in uint64
+	maxSafeDigits = 19
+)
+
+//cpuPrefix prefix of identifiers of /proc/stat lines with CPU times
+var cpuPrefix = []byte("cpu")
+
+//Parser parses /proc/stat in single pass over reusable buffer, parsing into the same sample again and again
+//does not allocate memory once buffer and sample grew to fit all CPUs; Parser must not be used concurrently
+type Parser struct {
+	buf []byte
+}
+
+//Parse reads CPU lines from the beginning of /proc/stat into sample, reusing memory of its CPUs and their extra columns,
+//reading stops at the first line which is not a CPU one; contents of sample are undefined when error is returned
+func (p *Parser) Parse(r io.Reader, sample *Sample) error {
+	return p.parse(r, sample, false)
+}
+
+//ParseAggregate reads only the line of all CPUs together into sample, leaving its CPUs empty,
+//lines of individual CPUs are neither read nor parsed when they are not needed
+func (p *Parser) ParseAggregate(r io.Reader, sample *Sample) error {
+	return p.parse(r, sample, true)
+}
+
+//parse reads CPU lines into sample, only the first one when aggregateOnly is set
+func (p *Parser) parse(r io.Reader, sample *Sample, aggregateOnly bool) error {
+	if p.buf == nil {
+		p.buf = make([]byte, 0, initialBufferSize)
+	}
+	p.buf = p.buf[:0]
+	sample.Columns = 0
+	sample.CPUs = sample.CPUs[:0]
+	start, eof := 0, false
+	for lineNumber := 0; ; {
+		end := bytes.IndexByte(p.buf[start:], '\n')
+		if end < 0 && !eof {
+			//move unparsed part of line to the beginning of buffer and read the rest of it
+			p.buf = p.buf[:copy(p.buf, p.buf[start:])]
+			start = 0
+			if len(p.buf) == cap(p.buf) {
+				buf := make([]byte, len(p.buf), 2*cap(p.buf))
+				copy(buf, p.buf)
+				p.buf = buf
+			}
+			n, err := r.Read(p.buf[len(p.buf):cap(p.buf)])
+			p.buf = p.buf[:len(p.buf)+n]
+			if err == io.EOF {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+			continue
+		}
+
+		var line []byte
+		if end < 0 {
+			line, start = p.buf[start:], len(p.buf)
+		} else {
+			line, start = p.buf[start:start+end], start+end+1
+		}
+		cpuLine, err := parseLine(line, lineNumber, sample)
+		if err != nil {
+			return err
+		}
+		if !cpuLine || end < 0 || aggregateOnly {
+			break
+		}
+		lineNumber++
+	}
+	if sample.Columns == 0 {
+		return fmt.Errorf("No CPU lines found")
+	}
+	return nil
+}
+
+//parseLine parses CPU line into sample, it returns false when line is not a CPU one
+func parseLine(line []byte, lineNumber int, sample *Sample) (bool, error) {
+	id, line := nextField(line)
+	if !bytes.HasPrefix(id, cpuPrefix) {
+		return false, nil
+	}
+
+	var times *CPUTimes
+	if lineNumber == 0 {
+		if len(id) != len(cpuPrefix) {
+			return false, fmt.Errorf("Expected aggregate %q line, got %q", cpuPrefix, id)
+		}
+		times = &sample.All
+	} else {
+		cpuID, ok := parseUint(id[len(cpuPrefix):])
+		if !ok || cpuID > math.MaxInt32 {
+			return false, fmt.Errorf("Invalid CPU identifier %q", id)
+		}
+		//reuse CPU left by previous parsing together with its extra columns
+		if len(sample.CPUs) < cap(sample.CPUs) {
+			sample.CPUs = sample.CPUs[:len(sample.CPUs)+1]
+		} else {
+			sample.CPUs = append(sample.CPUs, CPUStat{})
+		}
+		cpu := &sample.CPUs[len(sample.CPUs)-1]
+		cpu.ID = int(cpuID)
+		times = &cpu.Times
+	}
+	*times = CPUTimes{Extra: times.Extra[:0]}
+
+	columns := 0
+	for field, rest := nextField(line); len(field) > 0; field, rest = nextField(rest) {
+		value, ok := parseUint(field)
+		if !ok {
+			return false, fmt.Errorf("Cannot parse %s times: invalid value %q", id, field)
+		}
+		if columns < knownColumns {
+			*times.column(columns) = value
+		} else {
+			times.Extra = append(times.Extra, value)
+		}
+		columns++
+	}
+	if len(times.Extra) == 0 {
+		times.Extra = nil
+	}
+
+	if lineNumber == 0 {
+		if columns < MinColumns {
+			return false, fmt.Errorf("Too few CPU columns. Expected at least {%d} is {%d}", MinColumns, columns)
+		}
+		sample.Columns = columns
+	} else if columns != sample.Columns {
+		return false, fmt.Errorf("Wrong data length of %s. Expected {%d} is {%d}", id, sample.Columns, columns)
+	}
+	return true, nil
+}
+
+//nextField returns the first whitespace separated field of line and the rest of line following it
+func nextField(line []byte) ([]byte, []byte) {
+	i := 0
+	for i < len(line) && isSpace(line[i]) {
+		i++
+	}
+	j := i
+	for j < len(line) && !isSpace(line[j]) {
+		j++
+	}
+	return line[i:j], line[j:]
+}
+
+//isSpace checks if character separates fields of /proc/stat
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\v' || c == '\f'
+}
+
+//parseUint parses decimal number without allocating memory
+func parseUint(field []byte) (uint64, bool) {
+	if len(field) == 0 {
+		return 0, false
+	}
+	var value uint64
+	for i, c := range field {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		digit := uint64(c - '0')
+		//numbers with up to 19 digits cannot overflow, so that the check is skipped for most counters
+		if i >= maxSafeDigits && value > (math.MaxUint64-digit)/10 {
+			return 0, false
+		}
+		value = value*10 + digit
+	}
+	return value, true
+}
@@ -0,0 +1,156 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procstat
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//generateProcStat generates /proc/stat of host with given number of CPUs, counters grow with tick
+func generateProcStat(cpus int, tick uint64) []byte {
+	content := &bytes.Buffer{}
+	line := func(id string, scale uint64) {
+		fmt.Fprintf(content, "%s %d %d %d %d %d %d %d %d %d %d\n", id,
+			scale*(3480506+40*tick), scale*1005574, scale*(209103+10*tick), scale*(49472588+50*tick), scale*57381,
+			scale*3, scale*424, 0, 0, 0)
+	}
+	line("cpu ", uint64(cpus))
+	for i := 0; i < cpus; i++ {
+		line(fmt.Sprintf("cpu%d", i), 1)
+	}
+	content.WriteString("intr 33594809" + strings.Repeat(" 0", 1024) + "\nctxt 1234\nbtime 1479398400\n")
+	return content.Bytes()
+}
+
+func TestParser(t *testing.T) {
+	Convey("Given parser", t, func() {
+		parser := &Parser{}
+		sample := &Sample{}
+
+		Convey("it should parse the same as Parse", func() {
+			So(parser.Parse(strings.NewReader(firstSample), sample), ShouldBeNil)
+			expected, err := Parse(strings.NewReader(firstSample))
+			So(err, ShouldBeNil)
+			So(sample, ShouldResemble, expected)
+		})
+
+		Convey("lines should be parsed when read in pieces", func() {
+			content := generateProcStat(64, 1)
+			So(parser.Parse(iotest.OneByteReader(bytes.NewReader(content)), sample), ShouldBeNil)
+			So(len(sample.CPUs), ShouldEqual, 64)
+			So(sample.CPUs[63].ID, ShouldEqual, 63)
+			So(sample.CPUs[63].Times.User, ShouldEqual, 3480546)
+			So(sample.All.User, ShouldEqual, 64*3480546)
+		})
+
+		Convey("lines longer than initial buffer should be parsed", func() {
+			line := "cpu" + strings.Repeat(" 1", initialBufferSize)
+			So(parser.Parse(strings.NewReader(line+"\n"+strings.Replace(line, "cpu", "cpu0", 1)), sample), ShouldBeNil)
+			So(sample.Columns, ShouldEqual, initialBufferSize)
+			So(len(sample.CPUs[0].Times.Extra), ShouldEqual, initialBufferSize-knownColumns)
+		})
+
+		Convey("sample should be reused by following parsing", func() {
+			So(parser.Parse(strings.NewReader("cpu 1 1 1 1 1 1 1 1 1 1 7\ncpu0 1 1 1 1 1 1 1 1 1 1 7\ncpu1 1 1 1 1 1 1 1 1 1 1 7\n"), sample), ShouldBeNil)
+			So(len(sample.CPUs), ShouldEqual, 2)
+			So(parser.Parse(strings.NewReader("cpu 2 2 2 2 2 2 2 2 2 2 8\ncpu1 2 2 2 2 2 2 2 2 2 2 8\n"), sample), ShouldBeNil)
+			So(len(sample.CPUs), ShouldEqual, 1)
+			So(sample.CPUs[0].ID, ShouldEqual, 1)
+			So(sample.CPUs[0].Times.Extra, ShouldResemble, []uint64{8})
+			So(sample.All.User, ShouldEqual, 2)
+
+			Convey("without allocating memory", func() {
+				content := generateProcStat(512, 1)
+				So(parser.Parse(bytes.NewReader(content), sample), ShouldBeNil)
+				reader := bytes.NewReader(content)
+				allocs := testing.AllocsPerRun(10, func() {
+					reader.Reset(content)
+					if err := parser.Parse(reader, sample); err != nil {
+						panic(err)
+					}
+				})
+				So(allocs, ShouldEqual, 0)
+			})
+		})
+
+		Convey("only the line of all CPUs should be read when CPUs are not needed", func() {
+			content := generateProcStat(512, 1)
+			reader := bytes.NewReader(content)
+			So(parser.ParseAggregate(reader, sample), ShouldBeNil)
+			So(sample.All.User, ShouldEqual, 512*3480546)
+			So(sample.Columns, ShouldEqual, 10)
+			So(sample.CPUs, ShouldBeEmpty)
+			So(reader.Len(), ShouldBeGreaterThan, 0)
+		})
+
+		Convey("counters overflowing 64 bits should be reported", func() {
+			So(parser.Parse(strings.NewReader("cpu 18446744073709551616 0 0 0\n"), sample), ShouldNotBeNil)
+		})
+	})
+}
+
+func benchmarkParse(b *testing.B, cpus int) {
+	content := generateProcStat(cpus, 1)
+	reader := bytes.NewReader(content)
+	parser := &Parser{}
+	sample := &Sample{}
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader.Reset(content)
+		if err := parser.Parse(reader, sample); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParse4CPUs(b *testing.B) {
+	benchmarkParse(b, 4)
+}
+
+func BenchmarkParse64CPUs(b *testing.B) {
+	benchmarkParse(b, 64)
+}
+
+func BenchmarkParse512CPUs(b *testing.B) {
+	benchmarkParse(b, 512)
+}
+
+func BenchmarkParseAggregate512CPUs(b *testing.B) {
+	content := generateProcStat(512, 1)
+	reader := bytes.NewReader(content)
+	parser := &Parser{}
+	sample := &Sample{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader.Reset(content)
+		if err := parser.ParseAggregate(reader, sample); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//Package procstat parses CPU lines of /proc/stat and calculates time spent by CPUs in each state between two samples,
+//it does not depend on Snap and may be used by any tool reading /proc/stat
+package procstat
+
+import (
+	"io"
+	"math"
+)
+
+const (
+	//MinColumns minimal number of CPU columns (up to idle) reported by any kernel
+	MinColumns = 4
+
+	//knownColumns number of CPU columns with dedicated fields in CPUTimes
+	knownColumns = 10
+)
+
+//ColumnNames names of CPU columns of /proc/stat in the order reported by kernel,
+//older kernels report only the leading part of this list, newer ones may report columns following it
+var ColumnNames = []string{"user", "nice", "system", "idle", "iowait", "irq", "softirq", "steal", "guest", "guest_nice"}
+
+//CPUTimes cumulative time (in jiffies) spent by CPU in each state since boot,
+//columns not reported by kernel are zero
+type CPUTimes struct {
+	User      uint64
+	Nice      uint64
+	System    uint64
+	Idle      uint64
+	Iowait    uint64
+	IRQ       uint64
+	SoftIRQ   uint64
+	Steal     uint64
+	Guest     uint64
+	GuestNice uint64
+	//Extra columns reported after guest_nice by kernels newer than this package
+	Extra []uint64
+}
+
+//CPUStat times of single CPU
+type CPUStat struct {
+	ID    int
+	Times CPUTimes
+}
+
+//Sample CPU lines of /proc/stat read at once
+type Sample struct {
+	//Columns number of columns reported in each CPU line
+	Columns int
+	//All times of all CPUs together (the "cpu" line)
+	All CPUTimes
+	//CPUs times of each CPU (the "cpuN" lines) in order reported by kernel
+	CPUs []CPUStat
+}
+
+//Parse reads CPU lines from the beginning of /proc/stat, reading stops at the first line which is not a CPU one
+func Parse(r io.Reader) (*Sample, error) {
+	sample := &Sample{}
+	if err := new(Parser).Parse(r, sample); err != nil {
+		return nil, err
+	}
+	return sample, nil
+}
+
+//column returns pointer to value of column with given position
+func (t *CPUTimes) column(i int) *uint64 {
+	switch i {
+	case 0:
+		return &t.User
+	case 1:
+		return &t.Nice
+	case 2:
+		return &t.System
+	case 3:
+		return &t.Idle
+	case 4:
+		return &t.Iowait
+	case 5:
+		return &t.IRQ
+	case 6:
+		return &t.SoftIRQ
+	case 7:
+		return &t.Steal
+	case 8:
+		return &t.Guest
+	case 9:
+		return &t.GuestNice
+	}
+	return &t.Extra[i-knownColumns]
+}
+
+//Column returns value of column with given position, positions follow ColumnNames and then Extra columns
+func (t CPUTimes) Column(i int) uint64 {
+	if i >= knownColumns+len(t.Extra) {
+		return 0
+	}
+	return *t.column(i)
+}
+
+//Total returns sum of all columns, guest columns included as they are reported by kernel
+func (t CPUTimes) Total() uint64 {
+	total := t.User + t.Nice + t.System + t.Idle + t.Iowait + t.IRQ + t.SoftIRQ + t.Steal + t.Guest + t.GuestNice
+	for _, value := range t.Extra {
+		total += value
+	}
+	return total
+}
+
+//Active returns time spent in all states except idle
+func (t CPUTimes) Active() uint64 {
+	return t.Total() - t.Idle
+}
+
+//Utilization returns time spent in all states except idle and iowait
+func (t CPUTimes) Utilization() uint64 {
+	return t.Active() - t.Iowait
+}
+
+//Delta returns time spent in each state since prev was read,
+//counters of 32-bit kernels which wrapped around between samples are handled
+func (t CPUTimes) Delta(prev CPUTimes) Delta {
+	delta := Delta{}
+	if len(t.Extra) > 0 {
+		delta.Extra = make([]int64, len(t.Extra))
+	}
+	for i := 0; i < knownColumns+len(t.Extra); i++ {
+		*delta.column(i) = counterDelta(t.Column(i), prev.Column(i))
+	}
+	return delta
+}
+
+//counterDelta returns difference between current and previous value of counter,
+//counter which went back is treated as wrapped around 32 bits when it fitted in 32 bits
+//and the difference modulo 2^32 is less than 2^31, otherwise it is reported as negative difference
+func counterDelta(curr, prev uint64) int64 {
+	if curr < prev && prev <= math.MaxUint32 {
+		if wrapped := uint32(curr) - uint32(prev); wrapped < 1<<31 {
+			return int64(wrapped)
+		}
+	}
+	return int64(curr - prev)
+}
@@ -0,0 +1,172 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procstat
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	firstSample = `cpu  400 10 100 1000 40 0 10 0 0 0
+cpu0 200 5 50 500 20 0 5 0 0 0
+cpu1 200 5 50 500 20 0 5 0 0 0
+intr 33594809 19 2 0 0 0 0 0 9 1 4 0 0 4 0 0 0 31 0 0
+ctxt 1234
+`
+	secondSample = `cpu  500 10 150 1200 30 0 10 0 0 0
+cpu0 300 5 50 600 10 0 5 0 0 0
+cpu2 200 5 100 600 20 0 5 0 0 0
+intr 33594809 19 2 0 0 0 0 0 9 1 4 0 0 4 0 0 0 31 0 0
+`
+)
+
+func TestParse(t *testing.T) {
+	Convey("Given /proc/stat contents", t, func() {
+		Convey("CPU lines should be parsed up to the first other line", func() {
+			sample, err := Parse(strings.NewReader(firstSample))
+			So(err, ShouldBeNil)
+			So(sample.Columns, ShouldEqual, 10)
+			So(sample.All, ShouldResemble, CPUTimes{User: 400, Nice: 10, System: 100, Idle: 1000, Iowait: 40, SoftIRQ: 10})
+			So(len(sample.CPUs), ShouldEqual, 2)
+			So(sample.CPUs[1].ID, ShouldEqual, 1)
+			So(sample.CPUs[1].Times.Idle, ShouldEqual, 500)
+		})
+
+		Convey("older kernels should report leading columns only", func() {
+			sample, err := Parse(strings.NewReader("cpu 1 2 3 4\ncpu0 1 2 3 4\n"))
+			So(err, ShouldBeNil)
+			So(sample.Columns, ShouldEqual, 4)
+			So(sample.All.Total(), ShouldEqual, 10)
+			So(sample.All.Iowait, ShouldEqual, 0)
+			So(sample.All.Column(9), ShouldEqual, 0)
+		})
+
+		Convey("columns of newer kernels should be kept as extra ones", func() {
+			sample, err := Parse(strings.NewReader("cpu 1 1 1 1 1 1 1 1 1 1 7\ncpu0 1 1 1 1 1 1 1 1 1 1 7\n"))
+			So(err, ShouldBeNil)
+			So(sample.Columns, ShouldEqual, 11)
+			So(sample.All.Extra, ShouldResemble, []uint64{7})
+			So(sample.All.Column(10), ShouldEqual, 7)
+			So(sample.All.Total(), ShouldEqual, 17)
+		})
+
+		Convey("counters above 2^53 should be kept precisely", func() {
+			sample, err := Parse(strings.NewReader("cpu 9007199254740993 0 0 1\n"))
+			So(err, ShouldBeNil)
+			So(sample.All.User, ShouldEqual, uint64(9007199254740993))
+		})
+
+		Convey("invalid contents should be reported", func() {
+			for _, content := range []string{
+				"",
+				"intr 1 2 3\n",
+				"cpu 1 2 3\n",
+				"cpu0 1 2 3 4\n",
+				"cpu 1 2 3 4 5\ncpu0 1 2 3 4\n",
+				"cpu * # # 4\n",
+				"cpu 1 2 3 4\ncpuX 1 2 3 4\n",
+			} {
+				_, err := Parse(strings.NewReader(content))
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
+
+func TestDelta(t *testing.T) {
+	Convey("Given two samples", t, func() {
+		first, err := Parse(strings.NewReader(firstSample))
+		So(err, ShouldBeNil)
+		second, err := Parse(strings.NewReader(secondSample))
+		So(err, ShouldBeNil)
+
+		Convey("derived times should be calculated from columns", func() {
+			So(first.All.Total(), ShouldEqual, 1560)
+			So(first.All.Active(), ShouldEqual, 560)
+			So(first.All.Utilization(), ShouldEqual, 520)
+		})
+
+		Convey("time spent in each state should be calculated", func() {
+			delta := second.All.Delta(first.All)
+			So(delta.User, ShouldEqual, 100)
+			So(delta.Iowait, ShouldEqual, -10)
+			So(delta.Total(), ShouldEqual, 340)
+			So(delta.Active(), ShouldEqual, 140)
+			So(delta.Utilization(), ShouldEqual, 150)
+
+			percent, ok := delta.Percent(delta.Idle)
+			So(ok, ShouldBeTrue)
+			So(percent, ShouldAlmostEqual, 100*200/340.0)
+
+			Convey("counters which went back should not give percentages", func() {
+				_, ok := delta.Percent(delta.Iowait)
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("percentages should not be given when no time elapsed", func() {
+			delta := first.All.Delta(first.All)
+			So(delta.Total(), ShouldEqual, 0)
+			_, ok := delta.Percent(delta.User)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("CPUs present in both samples should be compared", func() {
+			delta, err := second.Delta(first)
+			So(err, ShouldBeNil)
+			So(delta.All.User, ShouldEqual, 100)
+			So(len(delta.CPUs), ShouldEqual, 1)
+			So(delta.CPUs[0].ID, ShouldEqual, 0)
+			So(delta.CPUs[0].Delta.Idle, ShouldEqual, 100)
+		})
+
+		Convey("counters of 32-bit kernels should wrap around", func() {
+			prev := CPUTimes{User: math.MaxUint32 - 9, Idle: 100}
+			curr := CPUTimes{User: 10, Idle: 120}
+			delta := curr.Delta(prev)
+			So(delta.User, ShouldEqual, 20)
+			So(delta.Total(), ShouldEqual, 40)
+			percent, ok := delta.Percent(delta.User)
+			So(ok, ShouldBeTrue)
+			So(percent, ShouldEqual, 50)
+
+			Convey("but counters going slightly back should not be taken for wrapped ones", func() {
+				delta := CPUTimes{Iowait: 90}.Delta(CPUTimes{Iowait: 100})
+				So(delta.Iowait, ShouldEqual, -10)
+			})
+
+			Convey("and 64-bit counters should not wrap", func() {
+				delta := CPUTimes{User: 10}.Delta(CPUTimes{User: math.MaxUint32 + 1})
+				So(delta.User, ShouldEqual, -math.MaxUint32+9)
+			})
+		})
+
+		Convey("samples with different columns should not be compared", func() {
+			narrow, err := Parse(strings.NewReader("cpu 1 2 3 4\n"))
+			So(err, ShouldBeNil)
+			_, err = second.Delta(narrow)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,272 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cpu/cpu"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+const (
+	//prometheusContentType content type of Prometheus text exposition format
+	prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	//openMetricsContentType content type of OpenMetrics text format
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+	//cpuLabel label with CPU identifier ("all" for aggregate)
+	cpuLabel = "cpu"
+
+	//modeLabel label with CPU state, e.g. user
+	modeLabel = "mode"
+
+	//prometheusReadTimeout time given to scraper to send request
+	prometheusReadTimeout = 10 * time.Second
+
+	//prometheusWriteTimeout time given to collection and writing of response, it covers warm-up sample
+	prometheusWriteTimeout = 30 * time.Second
+)
+
+//invalidLabelChars characters not allowed in Prometheus label names
+var invalidLabelChars = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+//promFamily Prometheus metric family, one per representation of CPU states reported as modes
+//and one per representation of each derived state
+type promFamily struct {
+	name    string // without _total suffix of counters
+	help    string
+	kind    cpu.MetricKind
+	samples []promSample
+}
+
+//promSample single sample of metric family
+type promSample struct {
+	labels string // formatted labels, e.g. {cpu="0",mode="user"}
+	value  string // formatted value, counters are kept as integers
+}
+
+//byLabels sorts samples of family by their formatted labels
+type byLabels []promSample
+
+func (b byLabels) Len() int           { return len(b) }
+func (b byLabels) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byLabels) Less(i, j int) bool { return b[i].labels < b[j].labels }
+
+//prometheusExporter serves CPU metrics in Prometheus and OpenMetrics text formats,
+//gauges are calculated over the interval since the previous scrape
+type prometheusExporter struct {
+	p   *cpu.Plugin
+	mts []plugin.Metric
+}
+
+//newPrometheusExporter creates exporter of all metrics available for given config,
+//metrics are collected once to get baseline of gauges for the first scrape
+func newPrometheusExporter(cfg plugin.Config) (*prometheusExporter, error) {
+	p := cpu.New()
+	mts, err := requestAllMetrics(p, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.CollectMetrics(mts); err != nil {
+		return nil, err
+	}
+	return &prometheusExporter{p: p, mts: mts}, nil
+}
+
+//ServeHTTP collects metrics and writes them in format accepted by scraper
+func (e *prometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	metrics, err := e.p.CollectMetrics(e.mts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	body := &bytes.Buffer{}
+	if err := writePrometheus(body, metrics, openMetrics); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if openMetrics {
+		w.Header().Set("Content-Type", openMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", prometheusContentType)
+	}
+	w.Write(body.Bytes())
+}
+
+//servePrometheus serves CPU metrics over HTTP until the server fails
+func servePrometheus(args []string) error {
+	flags := flag.NewFlagSet("prometheus", flag.ContinueOnError)
+	collector := newCollectorFlags(flags)
+	listen := flags.String("listen", ":9777", "address to listen on")
+	path := flags.String("path", "/metrics", "path under which metrics are served")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	exporter, err := newPrometheusExporter(collector.config())
+	if err != nil {
+		return err
+	}
+	defer exporter.p.Close()
+	mux := http.NewServeMux()
+	mux.Handle(*path, exporter)
+	server := &http.Server{
+		Addr:         *listen,
+		Handler:      mux,
+		ReadTimeout:  prometheusReadTimeout,
+		WriteTimeout: prometheusWriteTimeout,
+	}
+	return server.ListenAndServe()
+}
+
+//writePrometheus writes metrics in Prometheus text format or OpenMetrics text format,
+//e.g. /intel/procfs/cpu/3/user_jiffies becomes intel_procfs_cpu_jiffies_total{cpu="3",mode="user"};
+//derived states overlap with modes, so they get their own families to keep sums over modes right,
+//e.g. /intel/procfs/cpu/3/active_jiffies becomes intel_procfs_cpu_active_jiffies_total{cpu="3"}
+func writePrometheus(w io.Writer, metrics []plugin.Metric, openMetrics bool) error {
+	families := map[string]*promFamily{}
+	for _, metric := range metrics {
+		ns := metric.Namespace.Strings()
+		descriptor, ok := cpu.DescribeMetric(ns[len(ns)-1])
+		if !ok {
+			return fmt.Errorf("Unknown metric {%s}", metric.Namespace.String())
+		}
+		value, ok := formatSampleValue(metric.Data)
+		if !ok {
+			return fmt.Errorf("Unsupported value {%v} of metric {%s}", metric.Data, metric.Namespace.String())
+		}
+		name := strings.Join(ns[:len(ns)-2], "_") + "_" + descriptor.Representation
+		if descriptor.Derived {
+			name = strings.Join(ns[:len(ns)-2], "_") + "_" + descriptor.State + "_" + descriptor.Representation
+		}
+		family, ok := families[name]
+		if !ok {
+			family = &promFamily{
+				name: name,
+				help: fmt.Sprintf("Time spent by CPU in each mode, in %s", descriptor.Unit),
+				kind: descriptor.Kind,
+			}
+			if descriptor.Kind == cpu.GaugeKind {
+				family.help = fmt.Sprintf("Time spent by CPU in each mode since the previous scrape, in %s", descriptor.Unit)
+			}
+			if descriptor.State == "" || descriptor.Derived {
+				family.help = descriptor.Description
+			}
+			families[name] = family
+		}
+		labels := map[string]string{}
+		for key, value := range metric.Tags {
+			labels[invalidLabelChars.ReplaceAllString(key, "_")] = value
+		}
+		labels[cpuLabel] = ns[len(ns)-2]
+		if descriptor.State != "" && !descriptor.Derived {
+			labels[modeLabel] = descriptor.State
+		}
+		family.samples = append(family.samples, promSample{labels: formatLabels(labels), value: value})
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		family := families[name]
+		sampleName, promType := family.name, "gauge"
+		if family.kind == cpu.CumulativeKind {
+			sampleName, promType = family.name+"_total", "counter"
+			if !openMetrics {
+				//Prometheus text format names counter families after their samples
+				family.name = sampleName
+			}
+		}
+		if _, err := fmt.Fprintf(w, "# HELP %s %s.\n# TYPE %s %s\n", family.name, family.help, family.name, promType); err != nil {
+			return err
+		}
+		sort.Sort(byLabels(family.samples))
+		for _, sample := range family.samples {
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", sampleName, sample.labels, sample.value); err != nil {
+				return err
+			}
+		}
+	}
+	if openMetrics {
+		_, err := io.WriteString(w, "# EOF\n")
+		return err
+	}
+	return nil
+}
+
+//formatLabels returns labels sorted by name in Prometheus format, e.g. {cpu="0",mode="user"}
+func formatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[name])
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, value)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+//formatSampleValue formats metric value as sample value, integer counters are written exactly
+func formatSampleValue(data interface{}) (string, bool) {
+	if value, ok := data.(uint64); ok {
+		return strconv.FormatUint(value, 10), true
+	}
+	value, ok := toFloat(data)
+	if !ok {
+		return "", false
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64), true
+}
+
+//toFloat converts numeric metric value to float64
+func toFloat(data interface{}) (float64, bool) {
+	switch value := data.(type) {
+	case float64:
+		return value, true
+	case float32:
+		return float64(value), true
+	case uint64:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	}
+	return 0, false
+}
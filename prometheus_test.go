@@ -0,0 +1,115 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const nextProcStatSample = `cpu  150 0 150 900 0 0 0 0 0 0
+cpu0 150 0 150 900 0 0 0 0 0 0`
+
+func TestPrometheusExporter(t *testing.T) {
+	Convey("Given Prometheus exporter reading proc directory", t, func() {
+		procPath := newProcPath(procStatSample)
+		Reset(func() { os.RemoveAll(procPath) })
+		exporter, err := newPrometheusExporter(plugin.Config{"proc_path": procPath, "hostname": "node-17"})
+		So(err, ShouldBeNil)
+		server := httptest.NewServer(exporter)
+		Reset(server.Close)
+
+		scrape := func(accept string) (string, string) {
+			request, err := http.NewRequest("GET", server.URL, nil)
+			So(err, ShouldBeNil)
+			if accept != "" {
+				request.Header.Set("Accept", accept)
+			}
+			response, err := http.DefaultClient.Do(request)
+			So(err, ShouldBeNil)
+			defer response.Body.Close()
+			So(response.StatusCode, ShouldEqual, http.StatusOK)
+			body, err := ioutil.ReadAll(response.Body)
+			So(err, ShouldBeNil)
+			return string(body), response.Header.Get("Content-Type")
+		}
+
+		Convey("counters and gauges should be served in Prometheus text format", func() {
+			So(ioutil.WriteFile(filepath.Join(procPath, "stat"), []byte(nextProcStatSample), 0644), ShouldBeNil)
+			body, contentType := scrape("")
+			So(contentType, ShouldEqual, prometheusContentType)
+			So(body, ShouldContainSubstring, "# TYPE intel_procfs_cpu_jiffies_total counter\n")
+			So(body, ShouldContainSubstring, `intel_procfs_cpu_jiffies_total{cpu="0",hostname="node-17",mode="user"} 150`+"\n")
+			So(body, ShouldContainSubstring, "# TYPE intel_procfs_cpu_percentage gauge\n")
+			So(body, ShouldContainSubstring, `intel_procfs_cpu_delta{cpu="0",hostname="node-17",mode="user"} 50`+"\n")
+			So(body, ShouldContainSubstring, "# TYPE intel_procfs_cpu_interval_seconds gauge\n")
+			So(body, ShouldContainSubstring, `intel_procfs_cpu_interval_seconds{cpu="all",hostname="node-17"} `)
+			So(body, ShouldContainSubstring, `intel_procfs_cpu_percentage{cpu="all",hostname="node-17",mode="user"} 25`+"\n")
+			So(body, ShouldNotContainSubstring, "# EOF")
+		})
+
+		Convey("derived states should not be reported as modes", func() {
+			So(ioutil.WriteFile(filepath.Join(procPath, "stat"), []byte(nextProcStatSample), 0644), ShouldBeNil)
+			body, _ := scrape("")
+			So(body, ShouldNotContainSubstring, `mode="active"`)
+			So(body, ShouldNotContainSubstring, `mode="utilization"`)
+			So(body, ShouldContainSubstring, "# TYPE intel_procfs_cpu_active_jiffies_total counter\n")
+			So(body, ShouldContainSubstring, `intel_procfs_cpu_active_jiffies_total{cpu="0",hostname="node-17"} 300`+"\n")
+			So(body, ShouldContainSubstring, "# TYPE intel_procfs_cpu_utilization_percentage gauge\n")
+			So(body, ShouldContainSubstring, `intel_procfs_cpu_utilization_percentage{cpu="all",hostname="node-17"} 50`+"\n")
+		})
+
+		Convey("OpenMetrics should be served when accepted by scraper", func() {
+			body, contentType := scrape("application/openmetrics-text; version=1.0.0")
+			So(contentType, ShouldEqual, openMetricsContentType)
+			So(body, ShouldContainSubstring, "# TYPE intel_procfs_cpu_jiffies counter\n")
+			So(body, ShouldContainSubstring, `intel_procfs_cpu_jiffies_total{cpu="all",hostname="node-17",mode="idle"} 800`+"\n")
+			So(strings.HasSuffix(body, "# EOF\n"), ShouldBeTrue)
+		})
+
+		Convey("collection errors should be reported to scraper", func() {
+			So(os.Remove(filepath.Join(procPath, "stat")), ShouldBeNil)
+			response, err := http.Get(server.URL)
+			So(err, ShouldBeNil)
+			response.Body.Close()
+			So(response.StatusCode, ShouldEqual, http.StatusInternalServerError)
+		})
+	})
+
+	Convey("Given counters above 2^53", t, func() {
+		value, ok := formatSampleValue(uint64(9007199254740993))
+		So(ok, ShouldBeTrue)
+		So(value, ShouldEqual, "9007199254740993")
+	})
+
+	Convey("Given label values with special characters", t, func() {
+		So(formatLabels(map[string]string{"mode": "user", "note": "a \"b\"\\\n"}), ShouldEqual, `{mode="user",note="a \"b\"\\\n"}`)
+	})
+}
@@ -0,0 +1,128 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cpu/cpu"
+)
+
+//record saves snapshots of files read by plugin to session archive which can be replayed later
+func record(args []string) error {
+	flags := flag.NewFlagSet("record", flag.ContinueOnError)
+	procPath := flags.String("proc_path", "/proc", "path to proc directory")
+	output := flags.String("output", "", "path to session archive")
+	iterations := flags.Int("iterations", 10, "number of snapshots")
+	interval := flags.Duration("interval", time.Second, "interval between snapshots")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *output == "" {
+		return fmt.Errorf("Missing -output")
+	}
+	if *iterations < 1 {
+		return fmt.Errorf("Incorrect number of iterations {%d}", *iterations)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	session := cpu.NewSessionWriter(f)
+	for i := 1; i <= *iterations; i++ {
+		if i > 1 {
+			time.Sleep(*interval)
+		}
+		snapshot, err := cpu.TakeSnapshot(cpu.LiveFS, *procPath)
+		if err != nil {
+			return err
+		}
+		if err := session.Write(snapshot); err != nil {
+			return err
+		}
+	}
+	if err := session.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+//replay collects metrics from snapshots of session archive as if they were live and prints them like diagnose mode,
+//metrics are timestamped with time of snapshot
+func replay(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	collector := newCollectorFlags(flags)
+	input := flags.String("input", "", "path to session archive")
+	format := flags.String("format", tableFormat, "output format, "+tableFormat+" or "+jsonFormat)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *input == "" {
+		return fmt.Errorf("Missing -input")
+	}
+	if *format != tableFormat && *format != jsonFormat {
+		return fmt.Errorf("Unknown format {%s}, expected %s or %s", *format, tableFormat, jsonFormat)
+	}
+	if *collector.procPaths != "" {
+		return fmt.Errorf("Session can be replayed only from single proc_path")
+	}
+	f, err := os.Open(*input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	snapshots, err := cpu.ReadSession(f)
+	if err != nil {
+		return err
+	}
+
+	//snapshots are read from default proc_path
+	cfg := collector.config()
+	cfg["proc_path"] = "/proc"
+	replayed := &cpu.Replay{}
+	p := cpu.NewWithFileSystem(replayed)
+	defer p.Close()
+	for i, snapshot := range snapshots {
+		replayed.Advance(snapshot)
+		mts, err := requestAllMetrics(p, cfg)
+		if err != nil {
+			return err
+		}
+		metrics, err := p.CollectMetrics(mts)
+		if err != nil {
+			return err
+		}
+		for j := range metrics {
+			metrics[j].Timestamp = snapshot.Timestamp
+		}
+		iteration := diagnosedIteration{Iteration: i + 1, Timestamp: snapshot.Timestamp, Metrics: getDiagnosedMetrics(metrics)}
+		if err := writeDiagnosedIteration(out, *format, iteration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
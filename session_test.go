@@ -0,0 +1,69 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	Convey("Given proc directory", t, func() {
+		procPath := newProcPath(procStatSample)
+		Reset(func() { os.RemoveAll(procPath) })
+		archive := filepath.Join(procPath, "session.jsonl.gz")
+
+		Convey("recorded session should be replayed", func() {
+			So(record([]string{"-proc_path", procPath, "-output", archive, "-iterations", "2", "-interval", "1ms"}), ShouldBeNil)
+			// replayed data should not depend on live file
+			So(os.Remove(filepath.Join(procPath, "stat")), ShouldBeNil)
+
+			out := &bytes.Buffer{}
+			So(replay([]string{"-input", archive, "-format", "json", "-hostname", "node-17"}, out), ShouldBeNil)
+			decoder := json.NewDecoder(out)
+			for i := 1; i <= 2; i++ {
+				iteration := diagnosedIteration{}
+				So(decoder.Decode(&iteration), ShouldBeNil)
+				So(iteration.Iteration, ShouldEqual, i)
+				if i == 1 {
+					So(len(iteration.Metrics), ShouldEqual, 24)
+				} else {
+					// percentages cannot be calculated from identical snapshots, deltas and rates are zero
+					So(len(iteration.Metrics), ShouldEqual, 24*3+1)
+				}
+				So(iteration.Metrics[0].Tags["hostname"], ShouldEqual, "node-17")
+			}
+		})
+
+		Convey("incorrect arguments should be reported", func() {
+			So(record([]string{"-proc_path", procPath}), ShouldNotBeNil)
+			So(record([]string{"-proc_path", filepath.Join(procPath, "missing"), "-output", archive}), ShouldNotBeNil)
+			So(replay([]string{}, &bytes.Buffer{}), ShouldNotBeNil)
+			So(replay([]string{"-input", filepath.Join(procPath, "missing")}, &bytes.Buffer{}), ShouldNotBeNil)
+		})
+	})
+}
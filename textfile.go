@@ -0,0 +1,127 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cpu/cpu"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+//textfileWriter writes CPU metrics to .prom file read by textfile collector of node_exporter,
+//percentages are calculated from two samples separated by window
+type textfileWriter struct {
+	p      *cpu.Plugin
+	mts    []plugin.Metric
+	path   string
+	window time.Duration
+	sleep  func(time.Duration)
+}
+
+//newTextfileWriter creates writer of all metrics available for given config to given .prom file
+func newTextfileWriter(cfg plugin.Config, path string, window time.Duration) (*textfileWriter, error) {
+	if filepath.Ext(path) != ".prom" {
+		return nil, fmt.Errorf("Incorrect file name {%s}, textfile collector reads only *.prom files", filepath.Base(path))
+	}
+	p := cpu.New()
+	mts, err := requestAllMetrics(p, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &textfileWriter{p: p, mts: mts, path: path, window: window, sleep: time.Sleep}, nil
+}
+
+//write takes two samples separated by window and atomically replaces .prom file with metrics
+func (t *textfileWriter) write() error {
+	if _, err := t.p.CollectMetrics(t.mts); err != nil {
+		return err
+	}
+	t.sleep(t.window)
+	metrics, err := t.p.CollectMetrics(t.mts)
+	if err != nil {
+		return err
+	}
+	content := &bytes.Buffer{}
+	if err := writePrometheus(content, metrics, false); err != nil {
+		return err
+	}
+	return writeFileAtomically(t.path, content.Bytes())
+}
+
+//writeFileAtomically writes content to temporary file in the same directory and renames it,
+//so readers never see partially written file
+func writeFileAtomically(path string, content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+//writeTextfile writes CPU metrics to .prom file once, or periodically when interval is given
+func writeTextfile(args []string) error {
+	flags := flag.NewFlagSet("textfile", flag.ContinueOnError)
+	collector := newCollectorFlags(flags)
+	directory := flags.String("directory", "", "directory read by textfile collector of node_exporter")
+	name := flags.String("file", "cpu.prom", "name of written file")
+	window := flags.Duration("window", time.Second, "time between samples which percentages are calculated from")
+	interval := flags.Duration("interval", 0, "time between writes, the file is written once when not given")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *directory == "" {
+		return fmt.Errorf("Missing -directory")
+	}
+	writer, err := newTextfileWriter(collector.config(), filepath.Join(*directory, *name), *window)
+	if err != nil {
+		return err
+	}
+	defer writer.p.Close()
+	for {
+		if err := writer.write(); err != nil {
+			return err
+		}
+		if *interval <= 0 {
+			return nil
+		}
+		time.Sleep(*interval)
+	}
+}
@@ -0,0 +1,82 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTextfile(t *testing.T) {
+	Convey("Given textfile writer reading proc directory", t, func() {
+		procPath := newProcPath(procStatSample)
+		directory, err := ioutil.TempDir("", "snap-plugin-collector-cpu")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(procPath)
+			os.RemoveAll(directory)
+		})
+		path := filepath.Join(directory, "cpu.prom")
+		writer, err := newTextfileWriter(plugin.Config{"proc_path": procPath}, path, time.Minute)
+		So(err, ShouldBeNil)
+
+		Convey("metrics should be written with percentages from two samples", func() {
+			var slept time.Duration
+			writer.sleep = func(window time.Duration) {
+				slept = window
+				So(ioutil.WriteFile(filepath.Join(procPath, "stat"), []byte(nextProcStatSample), 0644), ShouldBeNil)
+			}
+			So(writer.write(), ShouldBeNil)
+			So(slept, ShouldEqual, time.Minute)
+
+			content, err := ioutil.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldContainSubstring, `intel_procfs_cpu_jiffies_total{cpu="0",hostname="`)
+			So(string(content), ShouldContainSubstring, `",mode="user"} 25`+"\n")
+
+			files, err := ioutil.ReadDir(directory)
+			So(err, ShouldBeNil)
+			So(len(files), ShouldEqual, 1)
+			So(files[0].Mode().Perm(), ShouldEqual, os.FileMode(0644))
+		})
+
+		Convey("failed collection should keep previous file", func() {
+			So(ioutil.WriteFile(path, []byte("previous\n"), 0644), ShouldBeNil)
+			So(os.Remove(filepath.Join(procPath, "stat")), ShouldBeNil)
+			So(writer.write(), ShouldNotBeNil)
+			content, err := ioutil.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "previous\n")
+		})
+	})
+
+	Convey("Given file name not read by textfile collector", t, func() {
+		_, err := newTextfileWriter(plugin.Config{}, "/tmp/cpu.txt", time.Second)
+		So(err, ShouldNotBeNil)
+	})
+}